@@ -1,66 +1,314 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/nullexp/finman-auth-service/internal/adapter/certs"
 	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
 	grpcDriver "github.com/nullexp/finman-auth-service/internal/adapter/driver/grpc"
 	authv1 "github.com/nullexp/finman-auth-service/internal/adapter/driver/grpc/proto/auth/v1"
+	httpDriver "github.com/nullexp/finman-auth-service/internal/adapter/driver/http"
 	driver "github.com/nullexp/finman-auth-service/internal/adapter/driver/service"
+	"github.com/nullexp/finman-auth-service/internal/adapter/health"
+	"github.com/nullexp/finman-auth-service/internal/adapter/lifecycle"
+	"github.com/nullexp/finman-auth-service/internal/adapter/logging"
+	"github.com/nullexp/finman-auth-service/internal/adapter/tracing"
+	drivenPort "github.com/nullexp/finman-auth-service/internal/port/driven"
 
+	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
 func main() {
-	log.Println("Starting the server")
-
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
 
+	logFormat := os.Getenv("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = logging.FormatText
+	}
+	logLevel := os.Getenv("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	logger, err := logging.New(os.Stderr, logFormat, logLevel)
+	if err != nil {
+		fatal("invalid logging configuration", "error", err)
+	}
+	// Also routes the standard log package, and so every remaining log line,
+	// through the redacting handler.
+	slog.SetDefault(logger)
+	slog.Info("Starting the server")
+
+	shutdownTimeout := optionalInt("SHUTDOWN_TIMEOUT_SECOND", 15)
+	// Dependencies are added to the manager as they are opened and closed in
+	// reverse once the servers drained.
+	manager := lifecycle.NewManager(time.Duration(shutdownTimeout)*time.Second, lifecycle.WithLogger(logger))
+	cleanup = func() { manager.Close() }
+
+	// Trace context is propagated even when this service exports no spans.
+	otel.SetTextMapPropagator(tracing.Propagator())
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		tracerProvider, err := tracing.NewOtlpTracerProvider(context.Background())
+		if err != nil {
+			fatal("failed to set up tracing", "error", err)
+		}
+		manager.AddCloser("tracer provider", func() error { return tracerProvider.Shutdown(context.Background()) })
+		otel.SetTracerProvider(tracerProvider)
+	}
+
 	jwtSecret := os.Getenv("JWT_SECRET")
+	jwtAlgorithm := os.Getenv("JWT_ALGORITHM")
+	jwtPrivateKeyFile := os.Getenv("JWT_PRIVATE_KEY_FILE")
+	jwtKeyId := os.Getenv("JWT_KEY_ID")
+	jwtKeyDir := os.Getenv("JWT_KEY_DIR")
 	jwtExpireMinute := os.Getenv("JWT_EXPIRE_MINUTE")
+	jwtIssuer := os.Getenv("JWT_ISSUER")
+	jwtAudience := os.Getenv("JWT_AUDIENCE")
+	oauthClientsFile := os.Getenv("OAUTH_CLIENTS_FILE")
+	rateLimitFile := os.Getenv("RATE_LIMIT_FILE")
+	rolePolicyFile := os.Getenv("ROLE_POLICY_FILE")
+	revocationStoreKind := os.Getenv("REVOCATION_STORE")
+	revocationDbPath := os.Getenv("REVOCATION_DB_PATH")
+	mfaIssuer := os.Getenv("MFA_ISSUER")
+	mfaStoreKind := os.Getenv("MFA_STORE")
+	mfaDbPath := os.Getenv("MFA_DB_PATH")
 	port := os.Getenv("PORT")
+	httpPort := os.Getenv("HTTP_PORT")
+	if httpPort == "" {
+		httpPort = "8090"
+	}
 	ip := os.Getenv("IP")
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	if metricsAddr == "" {
+		metricsAddr = "127.0.0.1:9090"
+	}
 	userServiceAddr := os.Getenv("USER_SERVICE_ADDR")
+	tlsFiles := certs.Files{Cert: os.Getenv("TLS_CERT_FILE"), Key: os.Getenv("TLS_KEY_FILE"), CA: os.Getenv("TLS_CLIENT_CA_FILE")}
+	userServiceTlsFiles := certs.Files{Cert: os.Getenv("USER_SERVICE_CERT_FILE"), Key: os.Getenv("USER_SERVICE_KEY_FILE"), CA: os.Getenv("USER_SERVICE_CA_FILE")}
+	userServiceTls := os.Getenv("USER_SERVICE_TLS") == "true" || userServiceTlsFiles != certs.Files{}
+	userServiceServerName := os.Getenv("USER_SERVICE_SERVER_NAME")
 	duration, err := strconv.Atoi(jwtExpireMinute)
 	if err != nil {
-		log.Fatal("duration should be a valid number")
+		fatal("duration should be a valid number")
+	}
+	leeway := optionalInt("JWT_LEEWAY_SECOND", 0)
+	rotationHour := optionalInt("JWT_KEY_ROTATION_HOUR", 0)
+	loginMinDuration := optionalInt("LOGIN_MIN_DURATION_MS", 250)
+	authCodeDuration := optionalInt("AUTHORIZATION_CODE_EXPIRE_SECOND", 60)
+	mfaChallengeDuration := optionalInt("MFA_CHALLENGE_EXPIRE_SECOND", 300)
+	healthCheckInterval := optionalInt("HEALTH_CHECK_INTERVAL_SECOND", 5)
+	tlsReloadInterval := time.Duration(optionalInt("TLS_RELOAD_INTERVAL_SECOND", 30)) * time.Second
+	throttleConfig := driven.ThrottleConfig{
+		BaseDelay:        time.Duration(optionalInt("LOGIN_BACKOFF_BASE_MS", 0)) * time.Millisecond,
+		MaxDelay:         time.Duration(optionalInt("LOGIN_BACKOFF_MAX_SECOND", 0)) * time.Second,
+		LockoutThreshold: optionalInt("LOGIN_LOCKOUT_THRESHOLD", 0),
+		LockoutDuration:  time.Duration(optionalInt("LOGIN_LOCKOUT_MINUTE", 0)) * time.Minute,
+		IpMaxAttempts:    optionalInt("LOGIN_IP_MAX_ATTEMPTS", 0),
+		IpWindow:         time.Duration(optionalInt("LOGIN_IP_WINDOW_SECOND", 0)) * time.Second,
 	}
+	resilienceConfig := driven.ResilienceConfig{
+		Timeout:          time.Duration(optionalInt("USER_SERVICE_TIMEOUT_MS", 2000)) * time.Millisecond,
+		MaxAttempts:      optionalInt("USER_SERVICE_MAX_ATTEMPTS", 3),
+		BaseBackoff:      time.Duration(optionalInt("USER_SERVICE_BACKOFF_BASE_MS", 100)) * time.Millisecond,
+		MaxBackoff:       time.Duration(optionalInt("USER_SERVICE_BACKOFF_MAX_MS", 1000)) * time.Millisecond,
+		FailureThreshold: optionalInt("USER_SERVICE_CIRCUIT_THRESHOLD", 5),
+		OpenDuration:     time.Duration(optionalInt("USER_SERVICE_CIRCUIT_OPEN_SECOND", 30)) * time.Second,
+	}
+	trustForwardedFor := os.Getenv("TRUST_FORWARDED_FOR") == "true"
+	refreshDuration := optionalInt("REFRESH_TOKEN_EXPIRE_MINUTE", 10080)
 
 	addr := fmt.Sprintf("%s:%v", ip, port)
 	// Create a TCP listener
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		fatal("failed to listen", "error", err)
+	}
+
+	metrics := driven.NewPrometheusMetrics()
+	interceptors := []grpc.UnaryServerInterceptor{
+		grpcDriver.LoggingInterceptor(logger, trustForwardedFor),
+		grpcDriver.MetricsInterceptor(metrics),
+	}
+	if rateLimitFile != "" {
+		rateLimits, err := grpcDriver.LoadRateLimitConfig(rateLimitFile)
+		if err != nil {
+			fatal("failed to load rate limits", "error", err)
+		}
+		rateLimiter := grpcDriver.NewRateLimiter(rateLimits, grpcDriver.ClientIpIdentity(trustForwardedFor))
+		interceptors = append(interceptors, rateLimiter.UnaryInterceptor())
+	}
+
+	serverOptions := []grpc.ServerOption{tracing.ServerOption(otel.GetTracerProvider()), grpc.ChainUnaryInterceptor(interceptors...)}
+	var serverCerts *certs.Reloader
+	if tlsFiles.Cert != "" {
+		serverCerts, err = certs.NewReloader(tlsFiles)
+		if err != nil {
+			fatal("failed to load TLS certificates", "error", err)
+		}
+		manager.AddWorker("server certificate reload", func(ctx context.Context) {
+			serverCerts.Run(ctx, tlsReloadInterval)
+		})
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(certs.ServerConfig(serverCerts))))
+		slog.Info("Serving gRPC over TLS", "mutual", tlsFiles.CA != "")
+	} else if tlsFiles.CA != "" || tlsFiles.Key != "" {
+		fatal("TLS_KEY_FILE and TLS_CLIENT_CA_FILE require TLS_CERT_FILE")
+	} else {
+		slog.Warn("Serving gRPC without TLS")
 	}
 
 	// Create a new gRPC server
-	s := grpc.NewServer()
+	s := grpc.NewServer(serverOptions...)
 
-	tokenService := driven.NewTokenService(jwtSecret, time.Duration(int(time.Minute)*duration))
+	revocationStore, closeRevocationStore, err := newRevocationStore(revocationStoreKind, revocationDbPath)
+	if err != nil {
+		fatal("failed to open revocation store", "error", err)
+	}
+	manager.AddCloser("revocation store", closeRevocationStore)
+
+	mfaStore, closeMfaStore, err := newMfaStore(mfaStoreKind, mfaDbPath)
+	if err != nil {
+		fatal("failed to open mfa store", "error", err)
+	}
+	manager.AddCloser("mfa store", closeMfaStore)
+	if mfaIssuer == "" {
+		mfaIssuer = "Finman"
+	}
 
-	log.Println("User service address: ", userServiceAddr)
-	conn, err := establishGRPCConnection(userServiceAddr, 10)
+	signingKey, err := loadSigningKey(jwtAlgorithm, jwtSecret, jwtPrivateKeyFile)
 	if err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+		fatal("failed to load signing key", "error", err)
+	}
+	if jwtKeyId != "" {
+		signingKey = signingKey.WithId(jwtKeyId)
 	}
-	defer conn.Close()
 
-	// Create UserService client
+	expireAfter := time.Duration(int(time.Minute) * duration)
+	leewayDuration := time.Duration(leeway) * time.Second
 
-	userService := driven.NewUserService(conn)
-	authService := driver.NewAuthService(userService, tokenService)
-	service := grpcDriver.NewAuthService(authService)
+	// Replaced keys keep verifying for as long as a token they signed can live.
+	keyRingOptions := []driven.KeyRingOption{driven.WithRotationGrace(expireAfter + leewayDuration)}
+	if jwtKeyDir != "" {
+		// The configured key only seeds the ring; once the file exists it wins.
+		keyRingOptions = append(keyRingOptions, driven.WithKeyRingFile(filepath.Join(jwtKeyDir, "keyring.json")))
+	}
+	keyRing, err := driven.NewKeyRing(signingKey, keyRingOptions...)
+	if err != nil {
+		fatal("failed to load key ring", "error", err)
+	}
+	if rotationHour > 0 && !keyRing.CanRotate() {
+		fatal("JWT_KEY_ROTATION_HOUR needs JWT_KEY_DIR and an RS256 or ES256 key")
+	}
+
+	manager.AddWorker("key rotation", func(ctx context.Context) {
+		keyRing.Run(ctx, time.Duration(rotationHour)*time.Hour)
+	})
+
+	tokenService := driven.NewTokenServiceWithKeyRing(keyRing, expireAfter,
+		driven.WithIssuer(jwtIssuer),
+		driven.WithAudience(jwtAudience),
+		driven.WithLeeway(leewayDuration),
+		driven.WithRevocationStore(revocationStore),
+	)
+
+	slog.Info("Connecting to the user service", "address", userServiceAddr)
+	userServiceCreds := insecure.NewCredentials()
+	var userServiceCerts *certs.Reloader
+	if userServiceTls {
+		userServiceCerts, err = certs.NewReloader(userServiceTlsFiles)
+		if err != nil {
+			fatal("failed to load user service TLS certificates", "error", err)
+		}
+		manager.AddWorker("user service certificate reload", func(ctx context.Context) {
+			userServiceCerts.Run(ctx, tlsReloadInterval)
+		})
+		userServiceCreds = credentials.NewTLS(certs.ClientConfig(userServiceCerts, userServiceAddr, userServiceServerName))
+	} else {
+		slog.Warn("Connecting to the user service without TLS")
+	}
+	conn, err := establishGRPCConnection(userServiceAddr,
+		grpc.WithTransportCredentials(userServiceCreds),
+		tracing.DialOption(otel.GetTracerProvider()),
+		grpc.WithUnaryInterceptor(driven.UserServiceMetricsInterceptor(metrics)),
+	)
+	if err != nil {
+		fatal("invalid user service address", "error", err)
+	}
+	manager.AddCloser("user service connection", conn.Close)
+
+	// Calls to the user service time out, retry transient failures and fail
+	// fast while it is down.
+	userService := driven.NewResilientUserService(driven.NewUserService(conn), resilienceConfig, driven.WithResilienceMetrics(metrics))
+	refreshTokenStore := driven.NewMemoryRefreshTokenStore()
+	// Failure counters are forgotten after a day without failed logins.
+	lockoutStore := driven.NewMemoryLockoutStore(24 * time.Hour)
+	loginThrottler := driven.NewLoginThrottler(throttleConfig, lockoutStore)
+	manager.AddWorker("lockout cleanup", func(ctx context.Context) {
+		lockoutStore.Run(ctx, time.Hour)
+	})
+	manager.AddWorker("login window cleanup", func(ctx context.Context) {
+		loginThrottler.Run(ctx, time.Minute)
+	})
+	authOptions := []driver.Option{
+		driver.WithRefreshTokens(refreshTokenStore, time.Duration(refreshDuration)*time.Minute),
+		driver.WithRevocationStore(revocationStore),
+		driver.WithKeyManager(keyRing),
+		driver.WithMinLoginDuration(time.Duration(loginMinDuration) * time.Millisecond),
+		driver.WithLoginThrottler(loginThrottler),
+		driver.WithMetrics(metrics),
+		driver.WithMfa(driven.NewTotp(mfaIssuer), mfaStore, driven.NewMemoryMfaChallengeStore(), time.Duration(mfaChallengeDuration)*time.Second),
+	}
+	if rolePolicyFile != "" {
+		roles, err := driven.LoadRolePolicy(rolePolicyFile)
+		if err != nil {
+			fatal("failed to load role policy", "error", err)
+		}
+		engine, err := driven.LoadPolicyEngine(rolePolicyFile)
+		if err != nil {
+			fatal("failed to load policy rules", "error", err)
+		}
+		authOptions = append(authOptions, driver.WithRolePolicy(roles), driver.WithPolicyEngine(engine))
+	}
+	if oauthClientsFile != "" {
+		clients, err := driven.LoadClientRegistry(oauthClientsFile)
+		if err != nil {
+			fatal("failed to load oauth clients", "error", err)
+		}
+		// The code flow redirects to the URIs registered for the clients, so
+		// it needs the registry and is enabled with it.
+		authOptions = append(authOptions,
+			driver.WithClientRegistry(clients),
+			driver.WithAuthorizationCodes(driven.NewMemoryAuthorizationCodeStore(), time.Duration(authCodeDuration)*time.Second),
+		)
+	} else {
+		slog.Info("OAUTH_CLIENTS_FILE is not set, the client_credentials grant and the authorization code flow are disabled")
+	}
+	authService := driver.NewAuthService(userService, tokenService, authOptions...)
+	var grpcOptions []grpcDriver.Option
+	httpOptions := []httpDriver.Option{httpDriver.WithLogger(logger)}
+	if trustForwardedFor {
+		grpcOptions = append(grpcOptions, grpcDriver.WithTrustForwardedFor())
+		httpOptions = append(httpOptions, httpDriver.WithTrustForwardedFor())
+	}
+	service := grpcDriver.NewAuthService(authService, grpcOptions...)
 
 	// Register the Greeter service
 	authv1.RegisterAuthServiceServer(s, service)
@@ -68,26 +316,138 @@ func main() {
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
 
-	// Log and start the server
-	log.Printf("gRPC server listening on %s", addr)
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	// Traffic only arrives once tokens can be issued, and stops on shutdown.
+	checker := health.NewChecker(authv1.AuthService_ServiceDesc.ServiceName)
+	checker.AddCheck("user_service", health.ConnectionCheck(conn))
+	checker.AddCheck("user_service_circuit", userService.Check)
+	checker.AddCheck("signing_keys", keyRing.Check)
+	if serverCerts != nil {
+		checker.AddCheck("tls_certificate", serverCerts.Check)
+	}
+	if userServiceCerts != nil {
+		checker.AddCheck("user_service_tls_certificate", userServiceCerts.Check)
+	}
+	healthpb.RegisterHealthServer(s, checker.Server())
+	httpOptions = append(httpOptions, httpDriver.WithReadiness(checker))
+	manager.AddWorker("readiness", func(ctx context.Context) {
+		checker.Run(ctx, time.Duration(healthCheckInterval)*time.Second)
+	})
+	manager.OnShutdown(checker.Shutdown)
+
+	// Serve the HTTP endpoints next to gRPC.
+	httpLis, err := net.Listen("tcp", fmt.Sprintf("%s:%v", ip, httpPort))
+	if err != nil {
+		fatal("failed to listen for HTTP", "error", err)
+	}
+	manager.AddHttpServer("http", &http.Server{Handler: httpDriver.NewHandler(authService, httpOptions...)}, httpLis)
+
+	// Metrics get a listener of their own so they are never served to the
+	// clients of the public endpoints.
+	metricsLis, err := net.Listen("tcp", metricsAddr)
+	if err != nil {
+		fatal("failed to listen for metrics", "error", err)
+	}
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("GET /metrics", metrics.Handler())
+	manager.AddHttpServer("metrics", &http.Server{Handler: metricsMux}, metricsLis)
+	manager.AddGrpcServer("grpc", s, lis)
+
+	// Run until SIGINT or SIGTERM, then drain the calls in flight.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := manager.Run(ctx); err != nil {
+		fatal("server stopped with errors", "error", err)
+	}
+}
+
+// cleanup closes what was opened before a fatal error. main points it at the
+// lifecycle manager as soon as that exists.
+var cleanup = func() {}
+
+// fatal logs msg with args as an error, closes the dependencies opened so far
+// and exits.
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	cleanup()
+	os.Exit(1)
+}
+
+// optionalInt reads the integer environment variable key, or fallback when it is unset.
+func optionalInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		fatal(key + " should be a valid number")
 	}
+	return n
 }
 
-// establishGRPCConnection establishes a gRPC connection with retry mechanism
-func establishGRPCConnection(serverAddr string, retryAttempts int) (*grpc.ClientConn, error) {
-	var conn *grpc.ClientConn
-	var err error
+// establishGRPCConnection creates the connection to the user service and
+// starts dialing right away. grpc.NewClient only fails on an invalid address
+// or options, so there is nothing to retry here: the connection keeps
+// reconnecting by itself, calls are retried by the ResilientUserService and
+// readiness stays down until the connection is up.
+func establishGRPCConnection(serverAddr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(serverAddr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	conn.Connect()
+	return conn, nil
+}
 
-	for i := 0; i < retryAttempts; i++ {
-		conn, err = grpc.NewClient(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials())) // insecure for test purpose
-		if err == nil {
-			log.Println("connected")
-			return conn, nil
+// newRevocationStore picks the revocation backend; kind is "memory" (default) or "bolt".
+func newRevocationStore(kind, path string) (drivenPort.RevocationStore, func() error, error) {
+	switch kind {
+	case "", "memory":
+		return driven.NewMemoryRevocationStore(), func() error { return nil }, nil
+	case "bolt":
+		store, err := driven.NewBoltRevocationStore(path)
+		if err != nil {
+			return nil, nil, err
 		}
-		log.Printf("Failed to connect (attempt %d): %v", i+1, err)
-		time.Sleep(2 * time.Second) // Retry after 2 seconds
+		return store, store.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown revocation store %q", kind)
+	}
+}
+
+// newMfaStore picks where MFA enrollments are kept; kind is "bolt" (default)
+// or "memory". A memory store forgets every enrollment on restart, which turns
+// MFA off, so it is only meant for tests and development.
+func newMfaStore(kind, path string) (drivenPort.MfaStore, func() error, error) {
+	switch kind {
+	case "memory":
+		slog.Warn("MFA enrollments are kept in memory and lost on restart")
+		return driven.NewMemoryMfaStore(), func() error { return nil }, nil
+	case "", "bolt":
+		if path == "" {
+			path = "mfa.db"
+		}
+		store, err := driven.NewBoltMfaStore(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, store.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown mfa store %q", kind)
+	}
+}
+
+// loadSigningKey builds the token signing key. HS256 (the default) signs with
+// the shared secret, other algorithms load a PEM private key from privateKeyFile.
+func loadSigningKey(alg, secret, privateKeyFile string) (driven.SigningKey, error) {
+	if alg == "" || alg == driven.AlgorithmHS256 {
+		if secret == "" {
+			return driven.SigningKey{}, fmt.Errorf("JWT_SECRET is required for %s", driven.AlgorithmHS256)
+		}
+		return driven.NewHMACKey(secret), nil
+	}
+	if privateKeyFile == "" {
+		return driven.SigningKey{}, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", alg)
 	}
-	return nil, err
+	return driven.LoadSigningKey(alg, privateKeyFile)
 }
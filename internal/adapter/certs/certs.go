@@ -0,0 +1,192 @@
+// Package certs loads the TLS certificates of the service from PEM files and
+// reloads them when the files change, so certificates can be rotated without
+// a restart.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Files names the PEM files of one side of a connection. Cert and Key hold
+// the certificate presented to the peer, CA the authorities the certificate
+// of the peer has to be signed by. Each part is optional.
+type Files struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+// Reloader holds the certificate and CA pool loaded from Files and replaces
+// them when the files change. A change that fails to load is logged and the
+// previous certificates stay in use.
+type Reloader struct {
+	files Files
+	now   func() time.Time
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	stamps  map[string]fileStamp
+	lastErr error
+}
+
+// fileStamp tells whether a file changed since it was loaded.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads files. Cert and Key have to be set together.
+func NewReloader(files Files) (*Reloader, error) {
+	if (files.Cert == "") != (files.Key == "") {
+		return nil, errors.New("a certificate needs both a certificate and a key file")
+	}
+	r := &Reloader{files: files, now: time.Now}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Certificate returns the certificate presented to peers, or nil when none
+// is configured.
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// CertPool returns the authorities peers are verified against, or nil when
+// no CA file is configured.
+func (r *Reloader) CertPool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// Reload loads the files again if any of them changed since the last load.
+func (r *Reloader) Reload() error {
+	r.mu.RLock()
+	changed := false
+	for _, path := range r.paths() {
+		stamp, err := stat(path)
+		if err != nil || stamp != r.stamps[path] {
+			changed = true
+			break
+		}
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	err := r.load()
+	r.mu.Lock()
+	r.lastErr = err
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	slog.Info("Reloaded TLS certificates", "cert_file", r.files.Cert, "ca_file", r.files.CA)
+	return nil
+}
+
+// Run checks the files for changes every interval until ctx is done.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := r.Reload(); err != nil {
+			slog.Error("Error reloading TLS certificates, keeping the previous ones", "cert_file", r.files.Cert, "ca_file", r.files.CA, "error", err)
+		}
+	}
+}
+
+// Check fails while the files could not be reloaded or the certificate in
+// use has expired.
+func (r *Reloader) Check(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.lastErr != nil {
+		return fmt.Errorf("reloading certificates failed: %w", r.lastErr)
+	}
+	if r.cert != nil && r.now().After(r.cert.Leaf.NotAfter) {
+		return fmt.Errorf("certificate %s expired at %s", r.files.Cert, r.cert.Leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (r *Reloader) paths() []string {
+	var paths []string
+	for _, path := range []string{r.files.Cert, r.files.Key, r.files.CA} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// load reads every file and swaps in the result only when all of them are
+// valid.
+func (r *Reloader) load() error {
+	stamps := map[string]fileStamp{}
+	for _, path := range r.paths() {
+		stamp, err := stat(path)
+		if err != nil {
+			return err
+		}
+		stamps[path] = stamp
+	}
+
+	var cert *tls.Certificate
+	if r.files.Cert != "" {
+		loaded, err := tls.LoadX509KeyPair(r.files.Cert, r.files.Key)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", r.files.Cert, err)
+		}
+		loaded.Leaf, err = x509.ParseCertificate(loaded.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", r.files.Cert, err)
+		}
+		cert = &loaded
+	}
+
+	var pool *x509.CertPool
+	if r.files.CA != "" {
+		data, err := os.ReadFile(r.files.CA)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("%s holds no PEM certificate", r.files.CA)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.pool, r.stamps = cert, pool, stamps
+	return nil
+}
+
+func stat(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
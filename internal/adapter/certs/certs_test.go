@@ -0,0 +1,305 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serial int64
+
+func newAuthority(t *testing.T, name string) authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	serial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a leaf for localhost signed by ca.
+func (ca authority) issue(t *testing.T, usage x509.ExtKeyUsage, notAfter time.Time) ([]byte, []byte) {
+	return ca.issueFor(t, usage, notAfter, "localhost", net.ParseIP("127.0.0.1"))
+}
+
+// issueFor returns the PEM certificate and key of a leaf for name and ips
+// signed by ca.
+func (ca authority) issueFor(t *testing.T, usage x509.ExtKeyUsage, notAfter time.Time, name string, ips ...net.IP) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  ips,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+}
+
+// write stores data at dir/name with a new modification time.
+func write(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	stamp := time.Now().Add(time.Duration(serial) * time.Second)
+	assert.NoError(t, os.Chtimes(path, stamp, stamp))
+	return path
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "ca")
+	cert, key := ca.issue(t, x509.ExtKeyUsageServerAuth, time.Now().Add(time.Hour))
+	files := Files{Cert: write(t, dir, "tls.crt", cert), Key: write(t, dir, "tls.key", key)}
+
+	r, err := NewReloader(files)
+	assert.NoError(t, err)
+	first := r.Certificate().Leaf.SerialNumber
+	assert.Nil(t, r.CertPool())
+
+	// Unchanged files are not read again.
+	assert.NoError(t, r.Reload())
+	assert.Equal(t, first, r.Certificate().Leaf.SerialNumber)
+
+	cert, key = ca.issue(t, x509.ExtKeyUsageServerAuth, time.Now().Add(time.Hour))
+	write(t, dir, "tls.crt", cert)
+	write(t, dir, "tls.key", key)
+	assert.NoError(t, r.Reload())
+	second := r.Certificate().Leaf.SerialNumber
+	assert.NotEqual(t, first, second)
+	assert.NoError(t, r.Check(context.Background()))
+
+	// A broken file keeps the previous certificate in use until it is fixed.
+	write(t, dir, "tls.crt", []byte("not a certificate"))
+	assert.Error(t, r.Reload())
+	assert.Equal(t, second, r.Certificate().Leaf.SerialNumber)
+	assert.Error(t, r.Check(context.Background()))
+	write(t, dir, "tls.crt", cert)
+	assert.NoError(t, r.Reload())
+	assert.NoError(t, r.Check(context.Background()))
+
+	r.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	assert.ErrorContains(t, r.Check(context.Background()), "expired")
+
+	_, err = NewReloader(Files{Cert: files.Cert})
+	assert.Error(t, err)
+}
+
+func TestMutualTls(t *testing.T) {
+	dir := t.TempDir()
+	serverCa, clientCa := newAuthority(t, "server ca"), newAuthority(t, "client ca")
+	serverCert, serverKey := serverCa.issue(t, x509.ExtKeyUsageServerAuth, time.Now().Add(time.Hour))
+	serverFiles := Files{
+		Cert: write(t, dir, "server.crt", serverCert),
+		Key:  write(t, dir, "server.key", serverKey),
+		CA:   write(t, dir, "client-ca.crt", clientCa.pem),
+	}
+	serverCerts, err := NewReloader(serverFiles)
+	assert.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(ServerConfig(serverCerts))))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	// call connects with a fresh connection, so every call runs a handshake.
+	call := func(files Files, serverName string) error {
+		clientCerts, err := NewReloader(files)
+		assert.NoError(t, err)
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(ClientConfig(clientCerts, lis.Addr().String(), serverName))))
+		assert.NoError(t, err)
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	clientCert, clientKey := clientCa.issue(t, x509.ExtKeyUsageClientAuth, time.Now().Add(time.Hour))
+	client := Files{
+		Cert: write(t, dir, "client.crt", clientCert),
+		Key:  write(t, dir, "client.key", clientKey),
+		CA:   write(t, dir, "server-ca.crt", serverCa.pem),
+	}
+	assert.NoError(t, call(client, ""))
+	assert.NoError(t, call(client, "localhost"))
+	assert.Error(t, call(client, "elsewhere"), "the server name is verified")
+	assert.Error(t, call(Files{CA: client.CA}, ""), "a client certificate is required")
+	assert.Error(t, call(Files{Cert: client.Cert, Key: client.Key, CA: write(t, dir, "other-ca.crt", clientCa.pem)}, ""), "the server is verified")
+
+	// Rotating the client CA applies to the next handshake.
+	rotatedCa := newAuthority(t, "rotated client ca")
+	write(t, dir, "client-ca.crt", rotatedCa.pem)
+	assert.NoError(t, serverCerts.Reload())
+	assert.Error(t, call(client, ""))
+
+	rotatedCert, rotatedKey := rotatedCa.issue(t, x509.ExtKeyUsageClientAuth, time.Now().Add(time.Hour))
+	write(t, dir, "client.crt", rotatedCert)
+	write(t, dir, "client.key", rotatedKey)
+	assert.NoError(t, call(client, ""))
+}
+
+func TestMutualTls_ResumedSessionsAreVerified(t *testing.T) {
+	dir := t.TempDir()
+	serverCa, clientCa := newAuthority(t, "server ca"), newAuthority(t, "client ca")
+	serverCert, serverKey := serverCa.issue(t, x509.ExtKeyUsageServerAuth, time.Now().Add(time.Hour))
+	serverCerts, err := NewReloader(Files{
+		Cert: write(t, dir, "server.crt", serverCert),
+		Key:  write(t, dir, "server.key", serverKey),
+		CA:   write(t, dir, "client-ca.crt", clientCa.pem),
+	})
+	assert.NoError(t, err)
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", ServerConfig(serverCerts))
+	assert.NoError(t, err)
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte{1})
+			}()
+		}
+	}()
+
+	clientCert, clientKey := clientCa.issue(t, x509.ExtKeyUsageClientAuth, time.Now().Add(time.Hour))
+	clientCerts, err := NewReloader(Files{
+		Cert: write(t, dir, "client.crt", clientCert),
+		Key:  write(t, dir, "client.key", clientKey),
+		CA:   write(t, dir, "server-ca.crt", serverCa.pem),
+	})
+	assert.NoError(t, err)
+	clientConfig := ClientConfig(clientCerts, lis.Addr().String(), "localhost")
+	clientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+	// dial reads the byte the server writes, which fails once the server
+	// rejected the handshake, and reports whether the session was resumed.
+	dial := func() (bool, error) {
+		conn, err := tls.Dial("tcp", lis.Addr().String(), clientConfig)
+		if err != nil {
+			return false, err
+		}
+		defer conn.Close()
+		if _, err := conn.Read(make([]byte, 1)); err != nil {
+			return false, err
+		}
+		return conn.ConnectionState().DidResume, nil
+	}
+
+	resumed, err := dial()
+	assert.NoError(t, err)
+	assert.False(t, resumed)
+	resumed, err = dial()
+	assert.NoError(t, err)
+	assert.True(t, resumed)
+
+	// A session of a client CA that is no longer trusted cannot be resumed.
+	write(t, dir, "client-ca.crt", newAuthority(t, "rotated client ca").pem)
+	assert.NoError(t, serverCerts.Reload())
+	_, err = dial()
+	assert.Error(t, err)
+}
+
+func TestMutualTls_ServerNameOfIpTargets(t *testing.T) {
+	dir := t.TempDir()
+	serverCa, clientCa := newAuthority(t, "server ca"), newAuthority(t, "client ca")
+	// The server holds a certificate of the CA, but for another host.
+	serverCert, serverKey := serverCa.issueFor(t, x509.ExtKeyUsageServerAuth, time.Now().Add(time.Hour), "elsewhere.test")
+	serverCerts, err := NewReloader(Files{
+		Cert: write(t, dir, "server.crt", serverCert),
+		Key:  write(t, dir, "server.key", serverKey),
+		CA:   write(t, dir, "client-ca.crt", clientCa.pem),
+	})
+	assert.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(ServerConfig(serverCerts))))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	clientCert, clientKey := clientCa.issue(t, x509.ExtKeyUsageClientAuth, time.Now().Add(time.Hour))
+	clientCerts, err := NewReloader(Files{
+		Cert: write(t, dir, "client.crt", clientCert),
+		Key:  write(t, dir, "client.key", clientKey),
+		CA:   write(t, dir, "server-ca.crt", serverCa.pem),
+	})
+	assert.NoError(t, err)
+	call := func(target, serverName string) error {
+		conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(credentials.NewTLS(ClientConfig(clientCerts, target, serverName))))
+		assert.NoError(t, err)
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	// Dialing by IP checks the IP against the certificate.
+	assert.Error(t, call(lis.Addr().String(), ""))
+	assert.Error(t, call("passthrough:///"+lis.Addr().String(), ""))
+	assert.NoError(t, call(lis.Addr().String(), "elsewhere.test"))
+}
+
+func TestTargetHost(t *testing.T) {
+	for target, host := range map[string]string{
+		"user-service:50051":         "user-service",
+		"10.0.0.5:50051":             "10.0.0.5",
+		"[::1]:50051":                "::1",
+		"dns:///user-service:50051":  "user-service",
+		"dns://8.8.8.8/user:50051":   "user",
+		"passthrough:///10.0.0.5:80": "10.0.0.5",
+		"user-service":               "user-service",
+		"":                           "",
+	} {
+		assert.Equal(t, host, targetHost(target), target)
+	}
+}
@@ -0,0 +1,125 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ServerConfig serves the certificate of r over gRPC. When r has a CA,
+// clients have to present a certificate it signed (mutual TLS). Both are
+// taken from r on every handshake, so reloaded files apply to new
+// connections right away.
+func ServerConfig(r *Reloader) *tls.Config {
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return r.Certificate(), nil
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+	}
+	if r.CertPool() != nil {
+		// ClientCAs is fixed per config, so every handshake gets a config
+		// with the current pool. The standard verification then also holds
+		// for resumed sessions, which skip VerifyPeerCertificate.
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				// Set by the gRPC credentials on config only.
+				NextProtos:     []string{"h2"},
+				GetCertificate: getCertificate,
+				ClientAuth:     tls.RequireAndVerifyClientCert,
+				ClientCAs:      r.CertPool(),
+			}, nil
+		}
+	}
+	return config
+}
+
+// ClientConfig presents the certificate of r, if any, to servers that ask
+// for one. Servers are verified against the CA of r, or the system roots
+// when r has none. serverName overrides the name expected in the server
+// certificate, which defaults to the host of the gRPC target.
+func ClientConfig(r *Reloader, target, serverName string) *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := r.Certificate(); cert != nil {
+				return cert, nil
+			}
+			// No certificate; the server decides whether that is enough.
+			return &tls.Certificate{}, nil
+		},
+	}
+	if r.CertPool() != nil {
+		// As in ServerConfig the chain is verified against the current pool,
+		// which RootCAs would pin. VerifyConnection runs on every handshake
+		// and checks the chain and the name, so nothing is skipped. The name
+		// is not taken from the connection state, which has none when an IP
+		// is dialed.
+		expected := serverName
+		if expected == "" {
+			expected = targetHost(target)
+		}
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if expected == "" {
+				return errors.New("no server name to verify the server certificate against")
+			}
+			rawCerts := make([][]byte, 0, len(state.PeerCertificates))
+			for _, cert := range state.PeerCertificates {
+				rawCerts = append(rawCerts, cert.Raw)
+			}
+			_, err := verify(rawCerts, x509.VerifyOptions{
+				Roots:     r.CertPool(),
+				DNSName:   expected,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			})
+			return err
+		}
+	}
+	return config
+}
+
+// targetHost returns the host of a gRPC target such as "host:port" or
+// "dns:///host:port", or an empty string when it has none.
+func targetHost(target string) string {
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return ""
+		}
+		target = strings.TrimPrefix(u.Path, "/")
+	}
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		// No port.
+		host = target
+	}
+	return strings.Trim(host, "[]")
+}
+
+// verify checks the chain the peer sent, leaf first, against opts.
+func verify(rawCerts [][]byte, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	if len(rawCerts) == 0 {
+		return nil, errors.New("peer sent no certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing peer certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	opts.Intermediates = x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	return certs[0].Verify(opts)
+}
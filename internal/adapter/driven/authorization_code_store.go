@@ -0,0 +1,45 @@
+package driven
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// MemoryAuthorizationCodeStore keeps authorization codes in process memory.
+// Codes live for seconds, so losing them on restart only fails logins that
+// were in flight.
+type MemoryAuthorizationCodeStore struct {
+	mu    sync.Mutex
+	codes map[string]model.AuthorizationCode
+}
+
+func NewMemoryAuthorizationCodeStore() *MemoryAuthorizationCodeStore {
+	return &MemoryAuthorizationCodeStore{codes: map[string]model.AuthorizationCode{}}
+}
+
+func (s *MemoryAuthorizationCodeStore) Save(ctx context.Context, code model.AuthorizationCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for hash, c := range s.codes {
+		if !now.Before(c.ExpiresAt) {
+			delete(s.codes, hash)
+		}
+	}
+	s.codes[code.Hash] = code
+	return nil
+}
+
+func (s *MemoryAuthorizationCodeStore) Take(ctx context.Context, hash string) (*model.AuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.codes[hash]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.codes, hash)
+	return &code, nil
+}
@@ -0,0 +1,74 @@
+package driven
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummySecretHash is compared against for unknown clients so that the
+// response time does not reveal which client ids exist.
+var dummySecretHash, _ = bcrypt.GenerateFromPassword([]byte("unknown-client"), bcrypt.DefaultCost)
+
+// MemoryClientRegistry keeps the registered OAuth2 clients in memory. Secrets
+// of confidential clients are stored as bcrypt hashes.
+type MemoryClientRegistry struct {
+	clients map[string]model.Client
+}
+
+func NewMemoryClientRegistry(clients ...model.Client) (*MemoryClientRegistry, error) {
+	r := &MemoryClientRegistry{clients: map[string]model.Client{}}
+	for _, client := range clients {
+		if client.Id == "" {
+			return nil, fmt.Errorf("client has no id")
+		}
+		if client.Public && client.SecretHash != "" {
+			return nil, fmt.Errorf("public client %s must not have a secret", client.Id)
+		}
+		if _, err := bcrypt.Cost([]byte(client.SecretHash)); err != nil && !client.Public {
+			return nil, fmt.Errorf("client %s: invalid secret hash: %w", client.Id, err)
+		}
+		if _, ok := r.clients[client.Id]; ok {
+			return nil, fmt.Errorf("duplicate client %q", client.Id)
+		}
+		r.clients[client.Id] = client
+	}
+	return r, nil
+}
+
+// LoadClientRegistry reads the clients from a JSON array of model.Client.
+func LoadClientRegistry(path string) (*MemoryClientRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var clients []model.Client
+	if err := json.Unmarshal(data, &clients); err != nil {
+		return nil, fmt.Errorf("invalid client file %s: %w", path, err)
+	}
+	return NewMemoryClientRegistry(clients...)
+}
+
+func (r *MemoryClientRegistry) GetClient(ctx context.Context, clientId string) (*model.Client, error) {
+	client, ok := r.clients[clientId]
+	if !ok {
+		return nil, nil
+	}
+	return &client, nil
+}
+
+func (r *MemoryClientRegistry) Authenticate(ctx context.Context, clientId, secret string) (*model.Client, error) {
+	client, ok := r.clients[clientId]
+	if !ok || client.Public {
+		bcrypt.CompareHashAndPassword(dummySecretHash, []byte(secret))
+		return nil, nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)) != nil {
+		return nil, nil
+	}
+	return &client, nil
+}
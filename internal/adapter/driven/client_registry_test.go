@@ -0,0 +1,41 @@
+package driven
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestClientRegistry_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "clients.json")
+	data := `[{"clientId": "reports", "secretHash": "` + string(hash) + `", "scopes": ["reports:read"]}]`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	registry, err := LoadClientRegistry(path)
+	assert.NoError(t, err)
+
+	client, err := registry.Authenticate(ctx, "reports", "s3cret")
+	assert.NoError(t, err)
+	assert.Equal(t, "reports", client.Id)
+	assert.True(t, client.HasScope("reports:read"))
+
+	client, err = registry.Authenticate(ctx, "reports", "wrong")
+	assert.NoError(t, err)
+	assert.Nil(t, client)
+
+	client, err = registry.Authenticate(ctx, "unknown", "s3cret")
+	assert.NoError(t, err)
+	assert.Nil(t, client)
+
+	_, err = NewMemoryClientRegistry(model.Client{Id: "plain", SecretHash: "s3cret"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package driven
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// JsonWebKey returns the public JWK of the key. It reports false for HMAC
+// keys, whose secret must never be published.
+func (k SigningKey) JsonWebKey() (model.JsonWebKey, bool) {
+	jwk := model.JsonWebKey{Use: "sig", Kid: k.Id, Alg: k.Algorithm()}
+
+	switch key := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeSegment(key.N.Bytes())
+		jwk.E = encodeSegment(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = encodeSegment(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeSegment(key.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeSegment(key)
+	default:
+		return model.JsonWebKey{}, false
+	}
+
+	return jwk, true
+}
+
+// thumbprint computes the RFC 7638 thumbprint of the key, used as its default kid.
+func (k SigningKey) thumbprint() string {
+	jwk, ok := k.JsonWebKey()
+	if !ok {
+		// Derive a stable id for HMAC keys without publishing anything that
+		// could be used to brute force a short secret.
+		secret, _ := k.PublicKey.([]byte)
+		sum := sha256.Sum256(append([]byte("kid:"), secret...))
+		return hex.EncodeToString(sum[:8])
+	}
+
+	// Required members only, in lexicographic order.
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return encodeSegment(sum[:])
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,90 @@
+package driven
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningKey_Thumbprint(t *testing.T) {
+	// Example key from RFC 7638, section 3.1.
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	assert.NoError(t, err)
+	key := SigningKey{Method: jwt.SigningMethodRS256, PublicKey: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}}
+
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", key.thumbprint())
+}
+
+func TestKeyRing_JsonWebKeySet(t *testing.T) {
+	hmacKey := NewHMACKey("testsecret")
+	_, ok := hmacKey.JsonWebKey()
+	assert.False(t, ok)
+
+	var keys []SigningKey
+	for _, alg := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		_, publicPath := writeKeyPair(t, alg)
+		key, err := LoadVerificationKey(alg, publicPath)
+		assert.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	kr, err := NewKeyRing(hmacKey, WithVerificationKeys(keys...))
+	assert.NoError(t, err)
+
+	set := kr.JsonWebKeySet()
+	assert.Len(t, set.Keys, 3)
+	byKty := map[string]model.JsonWebKey{}
+	for _, jwk := range set.Keys {
+		assert.Equal(t, "sig", jwk.Use)
+		assert.NotEmpty(t, jwk.Kid)
+		byKty[jwk.Kty] = jwk
+	}
+	assert.Equal(t, AlgorithmRS256, byKty["RSA"].Alg)
+	assert.Equal(t, "AQAB", byKty["RSA"].E)
+	assert.Equal(t, "P-256", byKty["EC"].Crv)
+	assert.Len(t, byKty["EC"].X, 43)
+	assert.Equal(t, "Ed25519", byKty["OKP"].Crv)
+
+	_, err = NewKeyRing(hmacKey, WithVerificationKeys(hmacKey))
+	assert.Error(t, err)
+}
+
+func TestTokenService_VerifiesByKeyId(t *testing.T) {
+	oldPrivate, _ := writeKeyPair(t, AlgorithmES256)
+	oldKey, err := LoadSigningKey(AlgorithmES256, oldPrivate)
+	assert.NoError(t, err)
+	newPrivate, _ := writeKeyPair(t, AlgorithmRS256)
+	newKey, err := LoadSigningKey(AlgorithmRS256, newPrivate)
+	assert.NoError(t, err)
+
+	oldToken, err := NewTokenServiceWithKey(oldKey, time.Hour).CreateToken(model.Subject{UserId: "1"})
+	assert.NoError(t, err)
+
+	header, err := jwt.DecodeSegment(strings.Split(oldToken, ".")[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(header), `"kid":"`+oldKey.Id+`"`)
+
+	keys, err := NewKeyRing(newKey, WithVerificationKeys(oldKey))
+	assert.NoError(t, err)
+	ts := NewTokenServiceWithKeyRing(keys, time.Hour)
+
+	_, err = ts.GetToken(oldToken)
+	assert.NoError(t, err)
+
+	newToken, err := ts.CreateToken(model.Subject{UserId: "1"})
+	assert.NoError(t, err)
+	_, err = ts.GetToken(newToken)
+	assert.NoError(t, err)
+
+	// The old key alone does not know the new kid.
+	_, err = NewTokenServiceWithKey(oldKey, time.Hour).GetToken(newToken)
+	assert.ErrorIs(t, err, domain.ErrTokenSignatureInvalid)
+}
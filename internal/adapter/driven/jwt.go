@@ -1,28 +1,117 @@
 package driven
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"log"
-	"strings"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
+	"github.com/nullexp/finman-auth-service/internal/domain"
 	"github.com/nullexp/finman-auth-service/internal/port/driven"
 	"github.com/nullexp/finman-auth-service/internal/port/model"
 )
 
 // TokenService is a struct that manages JWT tokens.
 type TokenService struct {
-	secret      string
+	keys        *KeyRing
 	expireAfter time.Duration
+	issuer      string
+	audience    string
+	leeway      time.Duration
+	now         func() time.Time
+	revocations driven.RevocationStore
 }
 
-// NewTokenService creates a new TokenService with the provided secret.
-func NewTokenService(secret string, expireAfter time.Duration) *TokenService {
-	return &TokenService{secret: secret, expireAfter: expireAfter}
+// TokenOption configures optional behaviour of a TokenService.
+type TokenOption func(*TokenService)
+
+// WithIssuer stamps iss on issued tokens and requires it when parsing.
+func WithIssuer(issuer string) TokenOption {
+	return func(ts *TokenService) {
+		ts.issuer = issuer
+	}
+}
+
+// WithAudience stamps aud on issued tokens and requires it when parsing.
+func WithAudience(audience string) TokenOption {
+	return func(ts *TokenService) {
+		ts.audience = audience
+	}
+}
+
+// WithLeeway sets the clock skew tolerated when checking exp, nbf and iat.
+func WithLeeway(leeway time.Duration) TokenOption {
+	return func(ts *TokenService) {
+		ts.leeway = leeway
+	}
+}
+
+// WithClock replaces time.Now, mainly so tests can be deterministic.
+func WithClock(now func() time.Time) TokenOption {
+	return func(ts *TokenService) {
+		ts.now = now
+	}
+}
+
+// WithRevocationStore makes parsing reject tokens revoked in store.
+func WithRevocationStore(store driven.RevocationStore) TokenOption {
+	return func(ts *TokenService) {
+		ts.revocations = store
+	}
+}
+
+// NewTokenService creates a new TokenService that signs HS256 tokens with the provided secret.
+func NewTokenService(secret string, expireAfter time.Duration, opts ...TokenOption) *TokenService {
+	return NewTokenServiceWithKey(NewHMACKey(secret), expireAfter, opts...)
+}
+
+// NewTokenServiceWithKey creates a new TokenService that signs and verifies with key.
+func NewTokenServiceWithKey(key SigningKey, expireAfter time.Duration, opts ...TokenOption) *TokenService {
+	if key.Id == "" {
+		key = withThumbprint(key)
+	}
+	// A single in-memory key with an id always forms a valid ring.
+	keys, _ := NewKeyRing(key)
+	return NewTokenServiceWithKeyRing(keys, expireAfter, opts...)
+}
+
+// NewTokenServiceWithKeyRing creates a new TokenService that signs with the
+// active key of keys and verifies tokens with the key matching their kid.
+func NewTokenServiceWithKeyRing(keys *KeyRing, expireAfter time.Duration, opts ...TokenOption) *TokenService {
+	ts := &TokenService{keys: keys, expireAfter: expireAfter, now: time.Now}
+	for _, opt := range opts {
+		opt(ts)
+	}
+	return ts
+}
+
+// ExpireAfter returns the lifetime of the access tokens created by CreateToken.
+func (ts TokenService) ExpireAfter() time.Duration {
+	return ts.expireAfter
+}
+
+// Leeway returns how long after exp tokens are still accepted.
+func (ts TokenService) Leeway() time.Duration {
+	return ts.leeway
+}
+
+// Issuer returns the iss claim stamped on issued tokens.
+func (ts TokenService) Issuer() string {
+	return ts.issuer
+}
+
+// Algorithm returns the algorithm new tokens are signed with.
+func (ts TokenService) Algorithm() string {
+	return ts.keys.SigningKey().Algorithm()
+}
+
+// Jwks returns the public keys tokens may be verified with.
+func (ts TokenService) Jwks() model.JsonWebKeySet {
+	return ts.keys.JsonWebKeySet()
 }
 
 // CreateToken generates a JWT token for the given subject.
@@ -30,13 +119,12 @@ func (ts TokenService) CreateToken(sb model.Subject) (string, error) {
 	// Marshal the subject to JSON.
 	data, err := json.Marshal(sb)
 	if err != nil {
-		log.Printf("Error marshaling subject: %v", err)
+		slog.Error("Error marshaling subject", "error", err)
 		return "", err
 	}
 
 	// Encode the JSON data to a base64 string.
 	enc := base64.RawStdEncoding.EncodeToString(data)
-	log.Printf("Encoded subject to base64: %s", enc)
 
 	// Create the token with the encoded subject.
 	return ts.createTokenWithText(enc, ts.expireAfter)
@@ -44,80 +132,180 @@ func (ts TokenService) CreateToken(sb model.Subject) (string, error) {
 
 // CreateTokenWithText generates a JWT token with the provided text and expireTime.
 func (ts TokenService) createTokenWithText(sb string, expireAfter time.Duration) (string, error) {
-	t := jwt.New(jwt.GetSigningMethod("HS256"))
-	t.Claims = model.StandardClaims{Subject: sb, ExpiresAt: time.Now().Add(expireAfter).Unix(), Identity: uuid.NewString()}
+	now := ts.now()
+	claims := model.StandardClaims{
+		Subject:       sb,
+		Issuer:        ts.issuer,
+		IssuedAt:      now.Unix(),
+		IssuedAtMilli: now.UnixMilli(),
+		NotBefore:     now.Unix(),
+		ExpiresAt:     now.Add(expireAfter).Unix(),
+		Identity:      uuid.NewString(),
+	}
+	if ts.audience != "" {
+		claims.Audience = []string{ts.audience}
+	}
+	return ts.sign(claims)
+}
 
-	// Sign the token with the secret.
-	tokenString, err := t.SignedString([]byte(ts.secret))
+// idTokenClaims is the payload of an OpenID Connect ID token.
+type idTokenClaims struct {
+	model.StandardClaims
+	Nonce             string `json:"nonce,omitempty"`
+	AuthTime          int64  `json:"auth_time,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	UpdatedAt         int64  `json:"updated_at,omitempty"`
+}
+
+// CreateIdToken generates an OpenID Connect ID token. Unlike access tokens its
+// sub is the plain user id and its audience is the client it was issued to.
+func (ts TokenService) CreateIdToken(ic model.IdTokenClaims) (string, error) {
+	now := ts.now()
+	claims := idTokenClaims{
+		StandardClaims: model.StandardClaims{
+			Subject:   ic.Subject,
+			Issuer:    ts.issuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ts.expireAfter).Unix(),
+			Identity:  uuid.NewString(),
+		},
+		Nonce:             ic.Nonce,
+		AuthTime:          unixOrZero(ic.AuthTime),
+		PreferredUsername: ic.PreferredUsername,
+		UpdatedAt:         unixOrZero(ic.UpdatedAt),
+	}
+	audience := ic.Audience
+	if audience == "" {
+		audience = ts.audience
+	}
+	if audience != "" {
+		claims.Audience = []string{audience}
+	}
+	return ts.sign(claims)
+}
+
+// sign signs claims with the active key and stamps its kid on the header.
+func (ts TokenService) sign(claims jwt.Claims) (string, error) {
+	key := ts.keys.SigningKey()
+	if !key.CanSign() {
+		return "", errVerifyOnlyKey
+	}
+	t := jwt.New(key.Method)
+	t.Header["kid"] = key.Id
+	t.Claims = claims
+
+	// Sign the token with the private key.
+	tokenString, err := t.SignedString(key.PrivateKey)
 	if err != nil {
-		log.Printf("Error signing token: %v", err)
+		slog.Error("Error signing token", "error", err)
 		return "", err
 	}
 
 	return tokenString, nil
 }
 
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
 // GetToken parses the given token string and returns the claims.
 func (ts TokenService) GetToken(tokenString string) (model.StandardClaims, error) {
+	sc, err := ts.parse(tokenString)
+	if err != nil {
+		slog.Debug("Rejected token", "error", err)
+		return sc, err
+	}
+
+	slog.Debug("Parsed token", "jti", sc.Identity, "expires_at", sc.ExpiresAt)
+	return sc, nil
+}
+
+// CheckToken validates the given token string.
+func (ts TokenService) CheckToken(tokenString string) (bool, error) {
+	_, err := ts.parse(tokenString)
+	// Check if there was an error parsing the token.
+	if err != nil {
+		slog.Debug("Rejected token", "error", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// parse verifies the signature of tokenString and validates its registered claims.
+func (ts TokenService) parse(tokenString string) (model.StandardClaims, error) {
 	sc := model.StandardClaims{}
 
-	// Parse the token.
-	rawToken, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	// Claims are validated below so that leeway, clock, issuer and audience apply.
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(tokenString, &sc, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ts.keys.VerificationKey(kid)
+		if !ok {
+			// None of our keys produced this token.
+			slog.Debug("Unknown key id", "kid", token.Header["kid"])
+			return nil, domain.ErrTokenSignatureInvalid
+		}
+		// Only accept the algorithm of the key so a public key can never be
+		// abused as an HMAC secret.
+		if token.Method.Alg() != key.Algorithm() {
+			slog.Debug("Unexpected signing method", "alg", token.Header["alg"])
 			return nil, errors.New("unexpected signing method")
 		}
-		return []byte(ts.secret), nil
+		return key.PublicKey, nil
 	})
 	if err != nil {
-		log.Printf("Error parsing token: %v", err)
-		return sc, err
+		return sc, toDomainError(err)
 	}
 
-	// Split the token into parts.
-	parts := strings.Split(rawToken.Raw, ".")
-	if len(parts) != 3 {
-		err = errors.New("invalid token format")
-		log.Printf("Error splitting token parts: %v", err)
+	err = sc.Validate(model.ClaimsValidation{
+		Issuer:   ts.issuer,
+		Audience: ts.audience,
+		Leeway:   ts.leeway,
+		Now:      ts.now,
+	})
+	if err != nil {
 		return sc, err
 	}
 
-	// Decode the base64 part of the token.
-	data, err := base64.RawStdEncoding.DecodeString(parts[1])
-	if err != nil {
-		log.Printf("Error decoding base64 token part: %v", err)
-		return sc, err
+	return sc, ts.checkRevocation(sc)
+}
+
+// checkRevocation rejects tokens whose jti was revoked or whose user had all
+// tokens issued before a cut-off revoked.
+func (ts TokenService) checkRevocation(sc model.StandardClaims) error {
+	if ts.revocations == nil {
+		return nil
 	}
 
-	// Unmarshal the JSON data into the standard claims.
-	err = json.Unmarshal(data, &sc)
+	ctx := context.Background()
+	revoked, err := ts.revocations.IsRevoked(ctx, sc.Identity)
 	if err != nil {
-		err = errors.New("unknown subject")
-		log.Printf("Error unmarshaling JSON data: %v", err)
-		return sc, err
+		return err
+	}
+	if revoked {
+		return domain.ErrTokenRevoked
 	}
 
-	log.Printf("Parsed token claims: %+v", sc)
-	return sc, nil
-}
-
-// CheckToken validates the given token string.
-func (ts TokenService) CheckToken(tokenString string) (bool, error) {
-	// Parse the token.
-	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			log.Printf("Unexpected signing method: %v", token.Header["alg"])
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(ts.secret), nil
-	})
-	// Check if there was an error parsing the token.
+	subject, err := ts.GetSubject(sc.Subject)
 	if err != nil {
-		log.Printf("Error checking token: %v", err)
-		return false, err
+		return domain.ErrTokenMalformed
+	}
+	cutoff, err := ts.revocations.RevokedBefore(ctx, subject.UserId)
+	if err != nil {
+		return err
+	}
+	// Compared in milliseconds, the precision of iat_ms. Tokens issued in the
+	// same millisecond as the cut-off are revoked too, and so are tokens from
+	// its second that only carry iat.
+	if !cutoff.IsZero() && sc.IssuedAtTime().UnixMilli() <= cutoff.UnixMilli() {
+		return domain.ErrTokenRevoked
 	}
 
-	log.Printf("Token is valid")
-	return true, nil
+	return nil
 }
 
 func (ts TokenService) GetSubject(subject string) (out model.Subject, err error) {
@@ -129,6 +317,25 @@ func (ts TokenService) GetSubject(subject string) (out model.Subject, err error)
 	return
 }
 
+// toDomainError translates a jwt parse failure into the matching domain error.
+func toDomainError(err error) error {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+
+	switch {
+	case errors.Is(ve.Inner, domain.ErrTokenSignatureInvalid):
+		return domain.ErrTokenSignatureInvalid
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return domain.ErrTokenMalformed
+	case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return domain.ErrTokenSignatureInvalid
+	default:
+		return domain.ErrTokenInvalid
+	}
+}
+
 // NewValidJwtClaim creates a new valid JWT claim with the given expiration time.
 func NewValidJwtClaim(expireTime time.Duration) driven.JwtClaim {
 	return model.StandardClaims{ExpiresAt: time.Now().Add(expireTime).Unix()}
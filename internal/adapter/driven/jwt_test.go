@@ -1,12 +1,14 @@
 package driven
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/nullexp/finman-auth-service/internal/domain"
 	"github.com/nullexp/finman-auth-service/internal/port/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,7 +18,8 @@ func TestNewTokenService(t *testing.T) {
 	expireAfter := time.Hour
 	ts := NewTokenService(secret, expireAfter)
 
-	assert.Equal(t, secret, ts.secret)
+	assert.Equal(t, AlgorithmHS256, ts.keys.SigningKey().Algorithm())
+	assert.Equal(t, []byte(secret), ts.keys.SigningKey().PrivateKey)
 	assert.Equal(t, expireAfter, ts.expireAfter)
 }
 
@@ -116,3 +119,122 @@ func TestTokenService_CheckTokenInvalidSecret(t *testing.T) {
 	assert.Error(t, err)
 	assert.False(t, valid)
 }
+
+func TestTokenService_GetTokenClaimsValidation(t *testing.T) {
+	secret := "testsecret"
+	issuedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	subject := model.Subject{UserId: uuid.New().String()}
+
+	issuer := NewTokenService(secret, time.Hour,
+		WithIssuer("finman-auth"),
+		WithAudience("finman"),
+		WithClock(func() time.Time { return issuedAt }),
+	)
+	token, err := issuer.CreateToken(subject)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		opts        []TokenOption
+		expectedErr error
+	}{
+		{
+			name: "valid within lifetime",
+			opts: []TokenOption{WithClock(func() time.Time { return issuedAt.Add(30 * time.Minute) })},
+		},
+		{
+			name:        "expired",
+			opts:        []TokenOption{WithClock(func() time.Time { return issuedAt.Add(time.Hour) })},
+			expectedErr: domain.ErrTokenExpired,
+		},
+		{
+			name: "expired but within leeway",
+			opts: []TokenOption{
+				WithClock(func() time.Time { return issuedAt.Add(time.Hour + 10*time.Second) }),
+				WithLeeway(time.Minute),
+			},
+		},
+		{
+			name:        "not yet valid",
+			opts:        []TokenOption{WithClock(func() time.Time { return issuedAt.Add(-time.Minute) })},
+			expectedErr: domain.ErrTokenNotYetValid,
+		},
+		{
+			name: "not yet valid but within leeway",
+			opts: []TokenOption{
+				WithClock(func() time.Time { return issuedAt.Add(-10 * time.Second) }),
+				WithLeeway(time.Minute),
+			},
+		},
+		{
+			name: "wrong issuer",
+			opts: []TokenOption{
+				WithClock(func() time.Time { return issuedAt }),
+				WithIssuer("someone-else"),
+			},
+			expectedErr: domain.ErrTokenIssuerInvalid,
+		},
+		{
+			name: "wrong audience",
+			opts: []TokenOption{
+				WithClock(func() time.Time { return issuedAt }),
+				WithIssuer("finman-auth"),
+				WithAudience("another-service"),
+			},
+			expectedErr: domain.ErrTokenAudienceInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := NewTokenService(secret, time.Hour, tt.opts...)
+
+			_, err := ts.GetToken(token)
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			valid, err := ts.CheckToken(token)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedErr == nil, valid)
+		})
+	}
+}
+
+func TestTokenService_GetTokenRevoked(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore()
+	ts := NewTokenService("testsecret", time.Hour, WithRevocationStore(store))
+
+	subject := model.Subject{UserId: uuid.New().String()}
+	token, err := ts.CreateToken(subject)
+	assert.NoError(t, err)
+
+	claims, err := ts.GetToken(token)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Revoke(ctx, claims.Identity, time.Unix(claims.ExpiresAt, 0)))
+	_, err = ts.GetToken(token)
+	assert.ErrorIs(t, err, domain.ErrTokenRevoked)
+
+	other, err := ts.CreateToken(subject)
+	assert.NoError(t, err)
+	_, err = ts.GetToken(other)
+	assert.NoError(t, err)
+
+	// Only tokens issued up to the cut-off are revoked, even within a second.
+	second := time.Now().Truncate(time.Second)
+	now := second.Add(100 * time.Millisecond)
+	clocked := NewTokenService("testsecret", time.Hour, WithRevocationStore(store), WithClock(func() time.Time { return now }))
+	before, err := clocked.CreateToken(subject)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.RevokeAllForUser(ctx, subject.UserId, second.Add(500*time.Millisecond), time.Now().Add(time.Hour)))
+	valid, err := clocked.CheckToken(before)
+	assert.ErrorIs(t, err, domain.ErrTokenRevoked)
+	assert.False(t, valid)
+
+	now = second.Add(600 * time.Millisecond)
+	after, err := clocked.CreateToken(subject)
+	assert.NoError(t, err)
+	_, err = clocked.GetToken(after)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,458 @@
+package driven
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// KeyRing holds the signing keys of the service. One key is active and signs
+// new tokens, verify-only keys still validate the tokens they signed, and
+// retired keys are kept for the record for another grace period before they
+// are dropped. Rotating the ring promotes a new key without invalidating
+// tokens that are still live.
+type KeyRing struct {
+	mu           sync.RWMutex
+	entries      []*keyRingEntry
+	verification []SigningKey
+	grace        time.Duration
+	path         string
+	now          func() time.Time
+}
+
+type keyRingEntry struct {
+	key       SigningKey
+	state     model.SigningKeyState
+	createdAt time.Time
+	retireAt  time.Time
+}
+
+// KeyRingOption configures optional behaviour of a KeyRing.
+type KeyRingOption func(*KeyRing)
+
+// WithVerificationKeys adds keys that verify tokens but never sign them.
+func WithVerificationKeys(keys ...SigningKey) KeyRingOption {
+	return func(kr *KeyRing) {
+		kr.verification = append(kr.verification, keys...)
+	}
+}
+
+// WithRotationGrace sets how long a replaced key keeps verifying tokens, and
+// how long it is listed as retired afterwards. It should be at least the
+// lifetime of an access token plus the leeway.
+func WithRotationGrace(grace time.Duration) KeyRingOption {
+	return func(kr *KeyRing) {
+		kr.grace = grace
+	}
+}
+
+// WithKeyRingFile persists the ring to path. When the file already exists its
+// keys take precedence over the ones the ring was created with.
+func WithKeyRingFile(path string) KeyRingOption {
+	return func(kr *KeyRing) {
+		kr.path = path
+	}
+}
+
+// WithKeyRingClock replaces time.Now, mainly so tests can be deterministic.
+func WithKeyRingClock(now func() time.Time) KeyRingOption {
+	return func(kr *KeyRing) {
+		kr.now = now
+	}
+}
+
+// NewKeyRing creates a ring that signs with active.
+func NewKeyRing(active SigningKey, opts ...KeyRingOption) (*KeyRing, error) {
+	kr := &KeyRing{now: time.Now}
+	for _, opt := range opts {
+		opt(kr)
+	}
+
+	now := kr.now()
+	kr.entries = []*keyRingEntry{{key: active, state: model.SigningKeyStateActive, createdAt: now}}
+	for _, key := range kr.verification {
+		kr.entries = append(kr.entries, &keyRingEntry{key: key, state: model.SigningKeyStateVerifyOnly, createdAt: now})
+	}
+
+	if kr.path != "" {
+		loaded, err := kr.load()
+		if err != nil {
+			return nil, err
+		}
+		if !loaded {
+			if err := kr.save(kr.entries); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range kr.entries {
+		if entry.key.Id == "" {
+			return nil, fmt.Errorf("%s key has no kid", entry.key.Algorithm())
+		}
+		if seen[entry.key.Id] {
+			return nil, fmt.Errorf("duplicate kid %q", entry.key.Id)
+		}
+		seen[entry.key.Id] = true
+	}
+
+	return kr, nil
+}
+
+// SigningKey returns the active key.
+func (kr *KeyRing) SigningKey() SigningKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.active().key
+}
+
+// VerificationKey returns the key identified by kid unless it was retired.
+// Tokens without a kid predate key ids and are checked against the active key.
+func (kr *KeyRing) VerificationKey(kid string) (SigningKey, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if kid == "" {
+		return kr.active().key, true
+	}
+
+	now := kr.now()
+	for _, entry := range kr.entries {
+		if entry.key.Id == kid && entry.verifies(now) {
+			return entry.key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// JsonWebKeySet returns the public keys tokens may currently be verified
+// with; HMAC keys are left out.
+func (kr *KeyRing) JsonWebKeySet() model.JsonWebKeySet {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	now := kr.now()
+	set := model.JsonWebKeySet{Keys: []model.JsonWebKey{}}
+	for _, entry := range kr.entries {
+		if !entry.verifies(now) {
+			continue
+		}
+		if jwk, ok := entry.key.JsonWebKey(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func (kr *KeyRing) ListKeys(ctx context.Context) ([]model.SigningKeyInfo, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	keys := make([]model.SigningKeyInfo, 0, len(kr.entries))
+	for _, entry := range kr.entries {
+		keys = append(keys, entry.info())
+	}
+	return keys, nil
+}
+
+// Rotate activates a new key of the same algorithm. It is refused for HS256,
+// whose new secret verifiers could not know, and for rings without a file,
+// which would lose the new key on restart and with it every token it signed.
+func (kr *KeyRing) Rotate(ctx context.Context) (model.SigningKeyInfo, error) {
+	if !kr.CanRotate() {
+		return model.SigningKeyInfo{}, domain.ErrKeyRotationUnsupported
+	}
+
+	// Generate outside the lock, RSA keys take a while.
+	key, err := GenerateSigningKey(kr.SigningKey().Algorithm())
+	if err != nil {
+		return model.SigningKeyInfo{}, err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	// The ring only changes once the new key is on disk, so it never signs
+	// with a key a restart would lose.
+	now := kr.now()
+	previous := *kr.active()
+	previous.state = model.SigningKeyStateVerifyOnly
+	previous.retireAt = now.Add(kr.grace)
+
+	entry := &keyRingEntry{key: key, state: model.SigningKeyStateActive, createdAt: now}
+	entries := make([]*keyRingEntry, 0, len(kr.entries)+1)
+	for _, e := range kr.entries {
+		if e.state == model.SigningKeyStateActive {
+			e = &previous
+		}
+		entries = append(entries, e)
+	}
+	entries = append(entries, entry)
+
+	if err := kr.save(entries); err != nil {
+		return model.SigningKeyInfo{}, err
+	}
+	kr.entries = entries
+
+	slog.Info("Rotated signing key", "active_kid", key.Id, "previous_kid", previous.key.Id, "verifies_until", previous.retireAt)
+	return entry.info(), nil
+}
+
+// CanRotate reports whether Rotate is supported.
+func (kr *KeyRing) CanRotate() bool {
+	return kr.path != "" && kr.SigningKey().Algorithm() != AlgorithmHS256
+}
+
+// Check signs a probe with the active key and verifies it, reporting whether
+// the ring can issue tokens that it will accept.
+func (kr *KeyRing) Check(ctx context.Context) error {
+	key := kr.SigningKey()
+	if !key.CanSign() {
+		return fmt.Errorf("active key %q has no private key", key.Id)
+	}
+	const probe = "probe"
+	signature, err := key.Method.Sign(probe, key.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("active key %q cannot sign: %w", key.Id, err)
+	}
+	if err := key.Method.Verify(probe, signature, key.PublicKey); err != nil {
+		return fmt.Errorf("active key %q cannot verify: %w", key.Id, err)
+	}
+	return nil
+}
+
+// RetireExpired retires verify-only keys whose grace period has passed and
+// drops their key material. Keys retired for another grace period are removed.
+func (kr *KeyRing) RetireExpired(ctx context.Context) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	now := kr.now()
+	changed := false
+	entries := kr.entries[:0]
+	for _, entry := range kr.entries {
+		switch {
+		case entry.state == model.SigningKeyStateVerifyOnly && !entry.verifies(now):
+			entry.state = model.SigningKeyStateRetired
+			entry.key = SigningKey{Id: entry.key.Id, Method: entry.key.Method}
+			changed = true
+			slog.Info("Retired signing key", "kid", entry.key.Id)
+		case entry.state == model.SigningKeyStateRetired && !now.Before(entry.retireAt.Add(kr.grace)):
+			changed = true
+			slog.Info("Dropped retired signing key", "kid", entry.key.Id)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	kr.entries = entries
+
+	if !changed {
+		return nil
+	}
+	return kr.save(kr.entries)
+}
+
+// Run retires expired keys and, when interval is positive, rotates the active
+// key once it is older than interval. It returns when ctx is done.
+func (kr *KeyRing) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := kr.RetireExpired(ctx); err != nil {
+			slog.Error("Error retiring signing keys", "error", err)
+		}
+
+		kr.mu.RLock()
+		due := interval > 0 && !kr.now().Before(kr.active().createdAt.Add(interval))
+		kr.mu.RUnlock()
+		if !due {
+			continue
+		}
+		if _, err := kr.Rotate(ctx); err != nil {
+			slog.Error("Error rotating signing key", "error", err)
+		}
+	}
+}
+
+// active returns the entry of the active key. Callers must hold the lock.
+func (kr *KeyRing) active() *keyRingEntry {
+	for _, entry := range kr.entries {
+		if entry.state == model.SigningKeyStateActive {
+			return entry
+		}
+	}
+	// NewKeyRing and load always leave exactly one active key.
+	panic("key ring has no active key")
+}
+
+func (e *keyRingEntry) verifies(now time.Time) bool {
+	switch e.state {
+	case model.SigningKeyStateActive:
+		return true
+	case model.SigningKeyStateVerifyOnly:
+		return e.retireAt.IsZero() || now.Before(e.retireAt)
+	default:
+		return false
+	}
+}
+
+func (e *keyRingEntry) info() model.SigningKeyInfo {
+	return model.SigningKeyInfo{
+		Id:        e.key.Id,
+		Algorithm: e.key.Algorithm(),
+		State:     e.state,
+		CreatedAt: e.createdAt,
+		RetireAt:  e.retireAt,
+	}
+}
+
+// keyRingRecord is the persisted form of a ring entry. Key holds the HMAC
+// secret or the PEM encoded private key, or the public key when Public is set.
+type keyRingRecord struct {
+	Id        string                `json:"kid"`
+	Algorithm string                `json:"alg"`
+	State     model.SigningKeyState `json:"state"`
+	CreatedAt time.Time             `json:"createdAt"`
+	RetireAt  time.Time             `json:"retireAt,omitempty"`
+	Key       string                `json:"key,omitempty"`
+	Public    bool                  `json:"public,omitempty"`
+}
+
+// save writes entries to the ring file, if any. Callers must hold the lock or
+// own the ring exclusively.
+func (kr *KeyRing) save(entries []*keyRingEntry) error {
+	if kr.path == "" {
+		return nil
+	}
+
+	records := make([]keyRingRecord, 0, len(entries))
+	for _, entry := range entries {
+		record := keyRingRecord{
+			Id:        entry.key.Id,
+			Algorithm: entry.key.Algorithm(),
+			State:     entry.state,
+			CreatedAt: entry.createdAt,
+			RetireAt:  entry.retireAt,
+		}
+		if entry.state != model.SigningKeyStateRetired {
+			var err error
+			record.Key, record.Public, err = encodeKeyMaterial(entry.key)
+			if err != nil {
+				return err
+			}
+		}
+		records = append(records, record)
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(kr.path), 0o700); err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a truncated ring behind.
+	tmp := kr.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, kr.path)
+}
+
+// load replaces the entries with the ones stored in the ring file. It reports
+// false when the file does not exist yet.
+func (kr *KeyRing) load() (bool, error) {
+	data, err := os.ReadFile(kr.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var records []keyRingRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return false, fmt.Errorf("invalid key ring file %s: %w", kr.path, err)
+	}
+
+	entries := make([]*keyRingEntry, 0, len(records))
+	active := 0
+	for _, record := range records {
+		key, err := decodeKeyMaterial(record)
+		if err != nil {
+			return false, fmt.Errorf("invalid key %s in %s: %w", record.Id, kr.path, err)
+		}
+		if record.State == model.SigningKeyStateActive {
+			active++
+		}
+		entries = append(entries, &keyRingEntry{key: key, state: record.State, createdAt: record.CreatedAt, retireAt: record.RetireAt})
+	}
+	if active != 1 {
+		return false, fmt.Errorf("key ring file %s has %d active keys", kr.path, active)
+	}
+
+	kr.entries = entries
+	return true, nil
+}
+
+func encodeKeyMaterial(key SigningKey) (string, bool, error) {
+	if key.Algorithm() == AlgorithmHS256 {
+		secret, _ := key.PublicKey.([]byte)
+		return string(secret), false, nil
+	}
+	if key.CanSign() {
+		data, err := encodePrivateKey(key.PrivateKey)
+		return string(data), false, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.PublicKey)
+	if err != nil {
+		return "", false, err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), true, nil
+}
+
+func decodeKeyMaterial(record keyRingRecord) (SigningKey, error) {
+	method := jwt.GetSigningMethod(record.Algorithm)
+	if method == nil {
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", record.Algorithm)
+	}
+
+	var (
+		key SigningKey
+		err error
+	)
+	switch {
+	case record.State == model.SigningKeyStateRetired:
+		key = SigningKey{Method: method}
+	case record.Algorithm == AlgorithmHS256:
+		key = NewHMACKey(record.Key)
+	case record.Public:
+		key, err = ParseVerificationKey(record.Algorithm, []byte(record.Key))
+	default:
+		key, err = ParseSigningKey(record.Algorithm, []byte(record.Key))
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return key.WithId(record.Id), nil
+}
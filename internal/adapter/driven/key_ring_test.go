@@ -0,0 +1,173 @@
+package driven
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyRing_Rotate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+
+	private, _ := writeKeyPair(t, AlgorithmES256)
+	first, err := LoadSigningKey(AlgorithmES256, private)
+	assert.NoError(t, err)
+	kr, err := NewKeyRing(first, WithRotationGrace(time.Hour), WithKeyRingClock(clock),
+		WithKeyRingFile(filepath.Join(t.TempDir(), "keyring.json")))
+	assert.NoError(t, err)
+	ts := NewTokenServiceWithKeyRing(kr, time.Hour, WithClock(clock))
+
+	oldToken, err := ts.CreateToken(model.Subject{UserId: "1"})
+	assert.NoError(t, err)
+
+	rotated, err := kr.Rotate(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Id, rotated.Id)
+	assert.Equal(t, AlgorithmES256, rotated.Algorithm)
+	assert.Equal(t, model.SigningKeyStateActive, rotated.State)
+	assert.Equal(t, rotated.Id, kr.SigningKey().Id)
+	assert.Len(t, kr.JsonWebKeySet().Keys, 2)
+
+	// Tokens signed by the previous key keep verifying during the grace period.
+	_, err = ts.GetToken(oldToken)
+	assert.NoError(t, err)
+	newToken, err := ts.CreateToken(model.Subject{UserId: "1"})
+	assert.NoError(t, err)
+	_, err = ts.GetToken(newToken)
+	assert.NoError(t, err)
+
+	keys, err := kr.ListKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, model.SigningKeyStateVerifyOnly, keys[0].State)
+	assert.Equal(t, now.Add(time.Hour), keys[0].RetireAt)
+
+	now = now.Add(time.Hour)
+	_, err = ts.GetToken(oldToken)
+	assert.ErrorIs(t, err, domain.ErrTokenSignatureInvalid)
+
+	assert.NoError(t, kr.RetireExpired(ctx))
+	keys, err = kr.ListKeys(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.SigningKeyStateRetired, keys[0].State)
+	assert.Len(t, kr.JsonWebKeySet().Keys, 1)
+
+	// Retired keys are dropped after another grace period.
+	now = now.Add(time.Hour)
+	assert.NoError(t, kr.RetireExpired(ctx))
+	keys, err = kr.ListKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, rotated.Id, keys[0].Id)
+}
+
+func TestKeyRing_RotateSaveFails(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	first, err := GenerateSigningKey(AlgorithmES256)
+	assert.NoError(t, err)
+	kr, err := NewKeyRing(first, WithKeyRingFile(path))
+	assert.NoError(t, err)
+
+	// A directory in place of the temporary file makes every save fail.
+	assert.NoError(t, os.Mkdir(path+".tmp", 0o700))
+	_, err = kr.Rotate(ctx)
+	assert.Error(t, err)
+
+	// The ring keeps signing with the key on disk.
+	assert.Equal(t, first.Id, kr.SigningKey().Id)
+	keys, err := kr.ListKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, model.SigningKeyStateActive, keys[0].State)
+	assert.True(t, keys[0].RetireAt.IsZero())
+}
+
+func TestKeyRing_RotateUnsupported(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keyring.json")
+
+	// Verifiers could not know a new HMAC secret.
+	kr, err := NewKeyRing(NewHMACKey("secret"), WithKeyRingFile(path))
+	assert.NoError(t, err)
+	assert.False(t, kr.CanRotate())
+	_, err = kr.Rotate(ctx)
+	assert.ErrorIs(t, err, domain.ErrKeyRotationUnsupported)
+
+	// A key only held in memory would be lost on restart.
+	key, err := GenerateSigningKey(AlgorithmES256)
+	assert.NoError(t, err)
+	kr, err = NewKeyRing(key)
+	assert.NoError(t, err)
+	assert.False(t, kr.CanRotate())
+	_, err = kr.Rotate(ctx)
+	assert.ErrorIs(t, err, domain.ErrKeyRotationUnsupported)
+}
+
+func TestKeyRing_File(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys", "keyring.json")
+
+	first, err := GenerateSigningKey(AlgorithmES256)
+	assert.NoError(t, err)
+	kr, err := NewKeyRing(first, WithKeyRingFile(path), WithRotationGrace(time.Hour))
+	assert.NoError(t, err)
+	oldToken, err := NewTokenServiceWithKeyRing(kr, time.Hour).CreateToken(model.Subject{UserId: "1"})
+	assert.NoError(t, err)
+	rotated, err := kr.Rotate(ctx)
+	assert.NoError(t, err)
+
+	// The file wins over the key the ring is created with.
+	other, err := GenerateSigningKey(AlgorithmES256)
+	assert.NoError(t, err)
+	reloaded, err := NewKeyRing(other, WithKeyRingFile(path))
+	assert.NoError(t, err)
+	assert.Equal(t, rotated.Id, reloaded.SigningKey().Id)
+	assert.Equal(t, kr.SigningKey().PrivateKey, reloaded.SigningKey().PrivateKey)
+
+	ts := NewTokenServiceWithKeyRing(reloaded, time.Hour)
+	_, err = ts.GetToken(oldToken)
+	assert.NoError(t, err)
+
+	original, err := kr.ListKeys(ctx)
+	assert.NoError(t, err)
+	keys, err := reloaded.ListKeys(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, len(original), len(keys))
+	for i := range keys {
+		assert.Equal(t, original[i].Id, keys[i].Id)
+		assert.Equal(t, original[i].State, keys[i].State)
+		assert.True(t, original[i].RetireAt.Equal(keys[i].RetireAt))
+	}
+}
+
+func TestKeyRing_Check(t *testing.T) {
+	ctx := context.Background()
+	key, err := GenerateSigningKey(AlgorithmES256)
+	assert.NoError(t, err)
+	kr, err := NewKeyRing(key)
+	assert.NoError(t, err)
+	assert.NoError(t, kr.Check(ctx))
+
+	other, err := GenerateSigningKey(AlgorithmES256)
+	assert.NoError(t, err)
+	mismatched := key
+	mismatched.PublicKey = other.PublicKey
+	kr, err = NewKeyRing(mismatched)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, kr.Check(ctx), "cannot verify")
+
+	verifyOnly := key
+	verifyOnly.PrivateKey = nil
+	kr, err = NewKeyRing(verifyOnly)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, kr.Check(ctx), "no private key")
+}
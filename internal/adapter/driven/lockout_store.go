@@ -0,0 +1,81 @@
+package driven
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// MemoryLockoutStore keeps failed login state in process memory. Run forgets
+// entries once they have been idle for retention, which bounds the memory
+// used by attempts against made-up usernames.
+type MemoryLockoutStore struct {
+	mu        sync.Mutex
+	failures  map[string]model.LoginFailures
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewMemoryLockoutStore(retention time.Duration) *MemoryLockoutStore {
+	return &MemoryLockoutStore{failures: map[string]model.LoginFailures{}, retention: retention, now: time.Now}
+}
+
+func (s *MemoryLockoutStore) Get(ctx context.Context, username string) (model.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failures[username], nil
+}
+
+func (s *MemoryLockoutStore) RecordFailure(ctx context.Context, username string, at time.Time) (model.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failures := s.failures[username]
+	failures.Count++
+	failures.LastFailure = at
+	s.failures[username] = failures
+	return failures, nil
+}
+
+func (s *MemoryLockoutStore) Lock(ctx context.Context, username string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failures := s.failures[username]
+	failures.LockedUntil = until
+	s.failures[username] = failures
+	return nil
+}
+
+func (s *MemoryLockoutStore) Reset(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, username)
+	return nil
+}
+
+// Run forgets idle entries every interval until ctx is done.
+func (s *MemoryLockoutStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.prune()
+	}
+}
+
+func (s *MemoryLockoutStore) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := s.now().Add(-s.retention)
+	for username, failures := range s.failures {
+		if failures.LastFailure.Before(cutoff) && failures.LockedUntil.Before(cutoff) {
+			delete(s.failures, username)
+		}
+	}
+}
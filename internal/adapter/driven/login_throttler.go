@@ -0,0 +1,204 @@
+package driven
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/driven"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// ThrottleConfig configures a LoginThrottler. A zero value disables the
+// corresponding protection.
+type ThrottleConfig struct {
+	// BaseDelay is the wait imposed after the first failure of a username;
+	// it doubles with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold failures in a row lock the username for LockoutDuration.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// At most IpMaxAttempts logins are accepted from one IP within IpWindow.
+	IpMaxAttempts int
+	IpWindow      time.Duration
+}
+
+// LoginThrottler slows down password guessing. It applies an exponential
+// backoff per username, a sliding window cap per client IP and a temporary
+// lockout after repeated failures. Only the lockout state is persisted in a
+// LockoutStore; the per-IP windows and the attempts in flight live in memory.
+//
+// Allow reserves the username until the outcome of the attempt is reported,
+// so guesses sent in parallel cannot all pass the backoff before the first
+// failure is counted.
+type LoginThrottler struct {
+	config   ThrottleConfig
+	lockouts driven.LockoutStore
+	now      func() time.Time
+
+	mu       sync.Mutex
+	ips      map[string][]time.Time
+	inFlight map[string]bool
+}
+
+func NewLoginThrottler(config ThrottleConfig, lockouts driven.LockoutStore) *LoginThrottler {
+	return &LoginThrottler{config: config, lockouts: lockouts, now: time.Now, ips: map[string][]time.Time{}, inFlight: map[string]bool{}}
+}
+
+func (lt *LoginThrottler) Allow(ctx context.Context, attempt model.LoginAttempt) error {
+	now := lt.now()
+	username := normalizeUsername(attempt.Username)
+	if err := lt.reserve(attempt.ClientIp, username, now); err != nil {
+		return err
+	}
+
+	failures, err := lt.lockouts.Get(ctx, username)
+	if err == nil && !failures.LockedUntil.IsZero() && !now.Before(failures.LockedUntil) {
+		// The lockout is over; the next failures count from zero again.
+		failures, err = model.LoginFailures{}, lt.lockouts.Reset(ctx, username)
+	}
+	if err != nil {
+		lt.release(username)
+		return err
+	}
+	if now.Before(failures.LockedUntil) {
+		lt.release(username)
+		return domain.WithRetryAfter(domain.ErrAccountLocked, failures.LockedUntil.Sub(now))
+	}
+	if failures.Count > 0 {
+		if next := failures.LastFailure.Add(lt.backoff(failures.Count)); now.Before(next) {
+			lt.release(username)
+			return domain.WithRetryAfter(domain.ErrLoginThrottled, next.Sub(now))
+		}
+	}
+	return nil
+}
+
+func (lt *LoginThrottler) Failed(ctx context.Context, attempt model.LoginAttempt) error {
+	username := normalizeUsername(attempt.Username)
+	defer lt.release(username)
+
+	now := lt.now()
+	failures, err := lt.lockouts.RecordFailure(ctx, username, now)
+	if err != nil {
+		return err
+	}
+	if lt.config.LockoutThreshold > 0 && failures.Count >= lt.config.LockoutThreshold {
+		return lt.lockouts.Lock(ctx, username, now.Add(lt.config.LockoutDuration))
+	}
+	return nil
+}
+
+func (lt *LoginThrottler) Succeeded(ctx context.Context, attempt model.LoginAttempt) error {
+	username := normalizeUsername(attempt.Username)
+	defer lt.release(username)
+	return lt.lockouts.Reset(ctx, username)
+}
+
+func (lt *LoginThrottler) Released(ctx context.Context, attempt model.LoginAttempt) error {
+	lt.release(normalizeUsername(attempt.Username))
+	return nil
+}
+
+func (lt *LoginThrottler) Unlock(ctx context.Context, username string) error {
+	return lt.lockouts.Reset(ctx, normalizeUsername(username))
+}
+
+// Run drops the IP windows that have emptied every interval until ctx is
+// done. Allow only trims the window of the IP at hand.
+func (lt *LoginThrottler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		lt.sweep()
+	}
+}
+
+func (lt *LoginThrottler) sweep() {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	start := lt.now().Add(-lt.config.IpWindow)
+	for ip, attempts := range lt.ips {
+		if attempts = dropUntil(attempts, start); len(attempts) == 0 {
+			delete(lt.ips, ip)
+		} else {
+			lt.ips[ip] = attempts
+		}
+	}
+}
+
+// reserve records an attempt from ip and rejects it when the window is full
+// or another attempt for username is still in flight. Usernames are only
+// reserved when failures are throttled at all.
+func (lt *LoginThrottler) reserve(ip, username string, now time.Time) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if ip != "" && lt.config.IpMaxAttempts > 0 {
+		attempts := dropUntil(lt.ips[ip], now.Add(-lt.config.IpWindow))
+		if len(attempts) >= lt.config.IpMaxAttempts {
+			lt.ips[ip] = attempts
+			return domain.WithRetryAfter(domain.ErrRateLimited, attempts[0].Add(lt.config.IpWindow).Sub(now))
+		}
+		lt.ips[ip] = append(attempts, now)
+	}
+
+	if lt.config.BaseDelay <= 0 && lt.config.LockoutThreshold <= 0 {
+		return nil
+	}
+	if lt.inFlight[username] {
+		retryAfter := lt.config.BaseDelay
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		return domain.WithRetryAfter(domain.ErrLoginThrottled, retryAfter)
+	}
+	lt.inFlight[username] = true
+	return nil
+}
+
+func (lt *LoginThrottler) release(username string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	delete(lt.inFlight, username)
+}
+
+// backoff returns the wait after failures consecutive failures.
+func (lt *LoginThrottler) backoff(failures int) time.Duration {
+	if lt.config.BaseDelay <= 0 {
+		return 0
+	}
+	delay := lt.config.BaseDelay
+	for i := 1; i < failures && delay < lt.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if lt.config.MaxDelay > 0 && delay > lt.config.MaxDelay {
+		delay = lt.config.MaxDelay
+	}
+	return delay
+}
+
+// dropUntil removes the leading timestamps at or before start, so an attempt
+// leaves the window exactly when the reported Retry-After elapses.
+func dropUntil(attempts []time.Time, start time.Time) []time.Time {
+	i := 0
+	for i < len(attempts) && !attempts[i].After(start) {
+		i++
+	}
+	return attempts[i:]
+}
+
+// normalizeUsername makes "Alice" and " alice" share one failure counter.
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,123 @@
+package driven
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestThrottler(config ThrottleConfig, now *time.Time) *LoginThrottler {
+	clock := func() time.Time { return *now }
+	lockouts := NewMemoryLockoutStore(time.Hour)
+	lockouts.now = clock
+	lt := NewLoginThrottler(config, lockouts)
+	lt.now = clock
+	return lt
+}
+
+func assertRetryAfter(t *testing.T, err error, expected error, retryAfter time.Duration) {
+	t.Helper()
+	assert.ErrorIs(t, err, expected)
+	var retry *domain.RetryError
+	if assert.True(t, errors.As(err, &retry)) {
+		assert.Equal(t, retryAfter, retry.RetryAfter)
+	}
+}
+
+func TestLoginThrottler_Backoff(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	lt := newTestThrottler(ThrottleConfig{BaseDelay: time.Second, MaxDelay: 3 * time.Second}, &now)
+	attempt := model.LoginAttempt{Username: "Alice"}
+
+	assert.NoError(t, lt.Allow(ctx, attempt))
+	assert.NoError(t, lt.Failed(ctx, attempt))
+	assertRetryAfter(t, lt.Allow(ctx, attempt), domain.ErrLoginThrottled, time.Second)
+
+	// The counter is shared regardless of case and surrounding spaces.
+	now = now.Add(time.Second)
+	assert.NoError(t, lt.Allow(ctx, model.LoginAttempt{Username: " alice"}))
+	assert.NoError(t, lt.Failed(ctx, attempt))
+	assertRetryAfter(t, lt.Allow(ctx, attempt), domain.ErrLoginThrottled, 2*time.Second)
+
+	assert.NoError(t, lt.Failed(ctx, attempt))
+	assertRetryAfter(t, lt.Allow(ctx, attempt), domain.ErrLoginThrottled, 3*time.Second)
+
+	assert.NoError(t, lt.Succeeded(ctx, attempt))
+	assert.NoError(t, lt.Allow(ctx, attempt))
+}
+
+func TestLoginThrottler_Lockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	lt := newTestThrottler(ThrottleConfig{LockoutThreshold: 3, LockoutDuration: time.Minute}, &now)
+	attempt := model.LoginAttempt{Username: "alice"}
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, lt.Allow(ctx, attempt))
+		assert.NoError(t, lt.Failed(ctx, attempt))
+	}
+	assertRetryAfter(t, lt.Allow(ctx, attempt), domain.ErrAccountLocked, time.Minute)
+	assert.NoError(t, lt.Allow(ctx, model.LoginAttempt{Username: "bob"}))
+
+	// Once the lockout ended failures count from zero again.
+	now = now.Add(time.Minute)
+	assert.NoError(t, lt.Allow(ctx, attempt))
+	assert.NoError(t, lt.Failed(ctx, attempt))
+	assert.NoError(t, lt.Allow(ctx, attempt))
+	assert.NoError(t, lt.Failed(ctx, attempt))
+	assert.NoError(t, lt.Allow(ctx, attempt))
+	assert.NoError(t, lt.Failed(ctx, attempt))
+	assert.ErrorIs(t, lt.Allow(ctx, attempt), domain.ErrAccountLocked)
+
+	assert.NoError(t, lt.Unlock(ctx, "Alice"))
+	assert.NoError(t, lt.Allow(ctx, attempt))
+}
+
+func TestLoginThrottler_ReservesUsername(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	lt := newTestThrottler(ThrottleConfig{BaseDelay: time.Second, MaxDelay: time.Minute}, &now)
+	attempt := model.LoginAttempt{Username: "alice"}
+
+	// A guess in parallel has to wait for the outcome of the first one.
+	assert.NoError(t, lt.Allow(ctx, attempt))
+	assertRetryAfter(t, lt.Allow(ctx, model.LoginAttempt{Username: "Alice"}), domain.ErrLoginThrottled, time.Second)
+	assert.NoError(t, lt.Allow(ctx, model.LoginAttempt{Username: "bob"}))
+
+	assert.NoError(t, lt.Failed(ctx, attempt))
+	assertRetryAfter(t, lt.Allow(ctx, attempt), domain.ErrLoginThrottled, time.Second)
+
+	// An attempt without an outcome frees the username without counting.
+	now = now.Add(time.Second)
+	assert.NoError(t, lt.Allow(ctx, attempt))
+	assert.NoError(t, lt.Released(ctx, attempt))
+	assert.NoError(t, lt.Allow(ctx, attempt))
+}
+
+func TestLoginThrottler_IpWindow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	lt := newTestThrottler(ThrottleConfig{IpMaxAttempts: 2, IpWindow: time.Minute}, &now)
+
+	assert.NoError(t, lt.Allow(ctx, model.LoginAttempt{Username: "alice", ClientIp: "10.0.0.1"}))
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, lt.Allow(ctx, model.LoginAttempt{Username: "bob", ClientIp: "10.0.0.1"}))
+	assertRetryAfter(t, lt.Allow(ctx, model.LoginAttempt{Username: "carol", ClientIp: "10.0.0.1"}), domain.ErrRateLimited, 30*time.Second)
+	assert.NoError(t, lt.Allow(ctx, model.LoginAttempt{Username: "carol", ClientIp: "10.0.0.2"}))
+
+	// The oldest attempt leaves the window and frees one slot.
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, lt.Allow(ctx, model.LoginAttempt{Username: "carol", ClientIp: "10.0.0.1"}))
+	assert.ErrorIs(t, lt.Allow(ctx, model.LoginAttempt{Username: "carol", ClientIp: "10.0.0.1"}), domain.ErrRateLimited)
+
+	// Windows that emptied are dropped by the periodic sweep.
+	now = now.Add(time.Minute)
+	lt.sweep()
+	assert.Empty(t, lt.ips)
+}
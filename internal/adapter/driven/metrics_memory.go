@@ -0,0 +1,121 @@
+package driven
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// MemoryMetrics counts what the service does in process memory so tests can
+// check it without scraping an exporter.
+type MemoryMetrics struct {
+	mu               sync.Mutex
+	serverCalls      map[[2]string]int
+	userServiceCalls map[[2]string]int
+	logins           map[model.LoginOutcome]int
+	tokensIssued     map[model.TokenKind]int
+	tokenValidations map[model.TokenInvalidReason]int
+	retries          map[string]int
+	circuitState     model.CircuitState
+}
+
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{
+		serverCalls:      map[[2]string]int{},
+		userServiceCalls: map[[2]string]int{},
+		logins:           map[model.LoginOutcome]int{},
+		tokensIssued:     map[model.TokenKind]int{},
+		tokenValidations: map[model.TokenInvalidReason]int{},
+		retries:          map[string]int{},
+		circuitState:     model.CircuitClosed,
+	}
+}
+
+func (m *MemoryMetrics) ObserveServerCall(method, code string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.serverCalls[[2]string{method, code}]++
+}
+
+func (m *MemoryMetrics) ObserveUserServiceCall(method, code string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.userServiceCalls[[2]string{method, code}]++
+}
+
+func (m *MemoryMetrics) CountLogin(outcome model.LoginOutcome) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logins[outcome]++
+}
+
+func (m *MemoryMetrics) CountTokenIssued(kind model.TokenKind) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokensIssued[kind]++
+}
+
+func (m *MemoryMetrics) CountTokenValidation(reason model.TokenInvalidReason) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokenValidations[reason]++
+}
+
+func (m *MemoryMetrics) CountUserServiceRetry(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries[method]++
+}
+
+func (m *MemoryMetrics) SetUserServiceCircuitState(state model.CircuitState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.circuitState = state
+}
+
+// ServerCalls returns how many calls of method ended with code.
+func (m *MemoryMetrics) ServerCalls(method, code string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.serverCalls[[2]string{method, code}]
+}
+
+// UserServiceCalls returns how many user service calls of method ended with code.
+func (m *MemoryMetrics) UserServiceCalls(method, code string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.userServiceCalls[[2]string{method, code}]
+}
+
+func (m *MemoryMetrics) Logins(outcome model.LoginOutcome) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.logins[outcome]
+}
+
+func (m *MemoryMetrics) TokensIssued(kind model.TokenKind) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokensIssued[kind]
+}
+
+func (m *MemoryMetrics) TokenValidations(reason model.TokenInvalidReason) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokenValidations[reason]
+}
+
+// UserServiceRetries returns how many calls of method were retried.
+func (m *MemoryMetrics) UserServiceRetries(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.retries[method]
+}
+
+// UserServiceCircuitState returns the state last recorded for the circuit breaker.
+func (m *MemoryMetrics) UserServiceCircuitState() model.CircuitState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.circuitState
+}
@@ -0,0 +1,138 @@
+package driven
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "finman_auth"
+
+// PrometheusMetrics keeps the metrics of the service in a Prometheus
+// registry of its own, next to the Go runtime and process metrics.
+type PrometheusMetrics struct {
+	registry         *prometheus.Registry
+	serverCalls      *prometheus.CounterVec
+	serverLatency    *prometheus.HistogramVec
+	userServiceCalls *prometheus.HistogramVec
+	logins           *prometheus.CounterVec
+	tokensIssued     *prometheus.CounterVec
+	tokenValidations *prometheus.CounterVec
+	retries          *prometheus.CounterVec
+	circuitState     *prometheus.GaugeVec
+}
+
+func NewPrometheusMetrics() *PrometheusMetrics {
+	m := &PrometheusMetrics{
+		registry: prometheus.NewRegistry(),
+		serverCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "grpc_server_handled_total",
+			Help:      "gRPC calls handled, by method and status code.",
+		}, []string{"method", "code"}),
+		serverLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "grpc_server_handling_seconds",
+			Help:      "Time spent handling gRPC calls, by method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		userServiceCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "user_service_request_seconds",
+			Help:      "Latency of calls to the user service, by method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "logins_total",
+			Help:      "Credential checks, by outcome.",
+		}, []string{"outcome"}),
+		tokensIssued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "tokens_issued_total",
+			Help:      "Tokens issued, by kind.",
+		}, []string{"kind"}),
+		tokenValidations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_validations_total",
+			Help:      "Tokens checked, by result: valid or the reason they were rejected.",
+		}, []string{"result"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "user_service_retries_total",
+			Help:      "User service calls retried after a transient failure, by method.",
+		}, []string{"method"}),
+		circuitState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "user_service_circuit_state",
+			Help:      "State of the circuit breaker in front of the user service: 1 for the current state, 0 for the others.",
+		}, []string{"state"}),
+	}
+	m.SetUserServiceCircuitState(model.CircuitClosed)
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.serverCalls,
+		m.serverLatency,
+		m.userServiceCalls,
+		m.logins,
+		m.tokensIssued,
+		m.tokenValidations,
+		m.retries,
+		m.circuitState,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *PrometheusMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *PrometheusMetrics) ObserveServerCall(method, code string, duration time.Duration) {
+	m.serverCalls.WithLabelValues(method, code).Inc()
+	m.serverLatency.WithLabelValues(method, code).Observe(duration.Seconds())
+}
+
+func (m *PrometheusMetrics) ObserveUserServiceCall(method, code string, duration time.Duration) {
+	m.userServiceCalls.WithLabelValues(method, code).Observe(duration.Seconds())
+}
+
+func (m *PrometheusMetrics) CountLogin(outcome model.LoginOutcome) {
+	m.logins.WithLabelValues(string(outcome)).Inc()
+}
+
+func (m *PrometheusMetrics) CountTokenIssued(kind model.TokenKind) {
+	m.tokensIssued.WithLabelValues(string(kind)).Inc()
+}
+
+func (m *PrometheusMetrics) CountTokenValidation(reason model.TokenInvalidReason) {
+	m.tokenValidations.WithLabelValues(validationResult(reason)).Inc()
+}
+
+func (m *PrometheusMetrics) CountUserServiceRetry(method string) {
+	m.retries.WithLabelValues(method).Inc()
+}
+
+func (m *PrometheusMetrics) SetUserServiceCircuitState(state model.CircuitState) {
+	for _, s := range model.CircuitStates {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		m.circuitState.WithLabelValues(string(s)).Set(value)
+	}
+}
+
+// validationResult is the label of a validation: "valid" or the lower-cased reason.
+func validationResult(reason model.TokenInvalidReason) string {
+	if reason == model.TokenInvalidReasonNone {
+		return "valid"
+	}
+	return strings.ToLower(string(reason))
+}
@@ -0,0 +1,42 @@
+package driven
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	metrics.ObserveServerCall("/auth.v1.AuthService/Login", "OK", 20*time.Millisecond)
+	metrics.ObserveUserServiceCall("/user.v1.UserService/GetUserById", "Unavailable", time.Second)
+	metrics.CountLogin(model.LoginOutcomeSuccess)
+	metrics.CountLogin(model.LoginOutcomeSuccess)
+	metrics.CountTokenIssued(model.TokenKindAccess)
+	metrics.CountTokenValidation(model.TokenInvalidReasonNone)
+	metrics.CountTokenValidation(model.TokenInvalidReasonExpired)
+	metrics.CountUserServiceRetry("GetUser")
+	metrics.SetUserServiceCircuitState(model.CircuitOpen)
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Body)
+	assert.NoError(t, err)
+
+	output := string(body)
+	assert.Contains(t, output, `finman_auth_grpc_server_handled_total{code="OK",method="/auth.v1.AuthService/Login"} 1`)
+	assert.Contains(t, output, `finman_auth_grpc_server_handling_seconds_count{code="OK",method="/auth.v1.AuthService/Login"} 1`)
+	assert.Contains(t, output, `finman_auth_user_service_request_seconds_count{code="Unavailable",method="/user.v1.UserService/GetUserById"} 1`)
+	assert.Contains(t, output, `finman_auth_logins_total{outcome="success"} 2`)
+	assert.Contains(t, output, `finman_auth_tokens_issued_total{kind="access"} 1`)
+	assert.Contains(t, output, `finman_auth_token_validations_total{result="valid"} 1`)
+	assert.Contains(t, output, `finman_auth_token_validations_total{result="expired"} 1`)
+	assert.Contains(t, output, `finman_auth_user_service_retries_total{method="GetUser"} 1`)
+	assert.Contains(t, output, `finman_auth_user_service_circuit_state{state="open"} 1`)
+	assert.Contains(t, output, `finman_auth_user_service_circuit_state{state="closed"} 0`)
+	assert.Contains(t, output, "go_goroutines")
+}
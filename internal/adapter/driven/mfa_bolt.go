@@ -0,0 +1,103 @@
+package driven
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+var mfaEnrollmentsBucket = []byte("mfa_enrollments")
+
+// BoltMfaStore persists MFA enrollments in a bbolt database file so they
+// survive restarts. The file holds the TOTP secrets and must be kept private.
+type BoltMfaStore struct {
+	db *bolt.DB
+}
+
+// NewBoltMfaStore opens (or creates) the database at path.
+func NewBoltMfaStore(path string) (*BoltMfaStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(mfaEnrollmentsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltMfaStore{db: db}, nil
+}
+
+func (s *BoltMfaStore) Get(ctx context.Context, userId string) (enrollment *model.MfaEnrollment, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		enrollment, err = getEnrollment(tx, userId)
+		return err
+	})
+	return
+}
+
+func (s *BoltMfaStore) Save(ctx context.Context, enrollment model.MfaEnrollment) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putEnrollment(tx, enrollment)
+	})
+}
+
+func (s *BoltMfaStore) UseStep(ctx context.Context, userId string, step int64) (used bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		enrollment, err := getEnrollment(tx, userId)
+		if err != nil || enrollment == nil || step <= enrollment.LastStep {
+			return err
+		}
+		enrollment.LastStep = step
+		used = true
+		return putEnrollment(tx, *enrollment)
+	})
+	return
+}
+
+func (s *BoltMfaStore) UseRecoveryCode(ctx context.Context, userId, hash string) (used bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		enrollment, err := getEnrollment(tx, userId)
+		if err != nil || enrollment == nil {
+			return err
+		}
+		enrollment.RecoveryCodeHashes, used = removeHash(enrollment.RecoveryCodeHashes, hash)
+		if !used {
+			return nil
+		}
+		return putEnrollment(tx, *enrollment)
+	})
+	return
+}
+
+func (s *BoltMfaStore) Close() error {
+	return s.db.Close()
+}
+
+func getEnrollment(tx *bolt.Tx, userId string) (*model.MfaEnrollment, error) {
+	data := tx.Bucket(mfaEnrollmentsBucket).Get([]byte(userId))
+	if data == nil {
+		return nil, nil
+	}
+	var enrollment model.MfaEnrollment
+	if err := json.Unmarshal(data, &enrollment); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func putEnrollment(tx *bolt.Tx, enrollment model.MfaEnrollment) error {
+	data, err := json.Marshal(enrollment)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(mfaEnrollmentsBucket).Put([]byte(enrollment.UserId), data)
+}
@@ -0,0 +1,61 @@
+package driven
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltMfaStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "mfa.db")
+
+	store, err := NewBoltMfaStore(path)
+	assert.NoError(t, err)
+	enrollment := model.MfaEnrollment{
+		UserId:             "123",
+		Secret:             "JBSWY3DPEHPK3PXP",
+		Confirmed:          true,
+		RecoveryCodeHashes: []string{"a", "b"},
+		LastStep:           10,
+		CreatedAt:          time.Unix(1700000000, 0).UTC(),
+	}
+	assert.NoError(t, store.Save(ctx, enrollment))
+	assert.NoError(t, store.Close())
+
+	// Enrollments survive reopening the database.
+	store, err = NewBoltMfaStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	stored, err := store.Get(ctx, "123")
+	assert.NoError(t, err)
+	assert.Equal(t, &enrollment, stored)
+
+	missing, err := store.Get(ctx, "456")
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+
+	used, err := store.UseStep(ctx, "123", 10)
+	assert.NoError(t, err)
+	assert.False(t, used)
+	used, err = store.UseStep(ctx, "123", 11)
+	assert.NoError(t, err)
+	assert.True(t, used)
+
+	used, err = store.UseRecoveryCode(ctx, "123", "a")
+	assert.NoError(t, err)
+	assert.True(t, used)
+	used, err = store.UseRecoveryCode(ctx, "123", "a")
+	assert.NoError(t, err)
+	assert.False(t, used)
+
+	stored, err = store.Get(ctx, "123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), stored.LastStep)
+	assert.Equal(t, []string{"b"}, stored.RecoveryCodeHashes)
+}
@@ -0,0 +1,110 @@
+package driven
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// MemoryMfaStore keeps MFA enrollments in process memory. They are lost on
+// restart, which turns MFA off for everyone; use BoltMfaStore in production.
+type MemoryMfaStore struct {
+	mu          sync.Mutex
+	enrollments map[string]model.MfaEnrollment
+}
+
+func NewMemoryMfaStore() *MemoryMfaStore {
+	return &MemoryMfaStore{enrollments: map[string]model.MfaEnrollment{}}
+}
+
+func (s *MemoryMfaStore) Get(ctx context.Context, userId string) (*model.MfaEnrollment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrollment, ok := s.enrollments[userId]
+	if !ok {
+		return nil, nil
+	}
+	enrollment.RecoveryCodeHashes = append([]string(nil), enrollment.RecoveryCodeHashes...)
+	return &enrollment, nil
+}
+
+func (s *MemoryMfaStore) Save(ctx context.Context, enrollment model.MfaEnrollment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrollment.RecoveryCodeHashes = append([]string(nil), enrollment.RecoveryCodeHashes...)
+	s.enrollments[enrollment.UserId] = enrollment
+	return nil
+}
+
+func (s *MemoryMfaStore) UseStep(ctx context.Context, userId string, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrollment, ok := s.enrollments[userId]
+	if !ok || step <= enrollment.LastStep {
+		return false, nil
+	}
+	enrollment.LastStep = step
+	s.enrollments[userId] = enrollment
+	return true, nil
+}
+
+func (s *MemoryMfaStore) UseRecoveryCode(ctx context.Context, userId, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrollment, ok := s.enrollments[userId]
+	if !ok {
+		return false, nil
+	}
+	remaining, used := removeHash(enrollment.RecoveryCodeHashes, hash)
+	enrollment.RecoveryCodeHashes = remaining
+	s.enrollments[userId] = enrollment
+	return used, nil
+}
+
+// removeHash returns hashes without hash and whether it was present.
+func removeHash(hashes []string, hash string) ([]string, bool) {
+	for i, h := range hashes {
+		if h == hash {
+			return append(hashes[:i:i], hashes[i+1:]...), true
+		}
+	}
+	return hashes, false
+}
+
+// MemoryMfaChallengeStore keeps pending MFA challenges in process memory.
+// Challenges live for minutes, so losing them on restart only fails logins
+// that were in flight.
+type MemoryMfaChallengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]model.MfaChallenge
+}
+
+func NewMemoryMfaChallengeStore() *MemoryMfaChallengeStore {
+	return &MemoryMfaChallengeStore{challenges: map[string]model.MfaChallenge{}}
+}
+
+func (s *MemoryMfaChallengeStore) Save(ctx context.Context, challenge model.MfaChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for hash, c := range s.challenges {
+		if !now.Before(c.ExpiresAt) {
+			delete(s.challenges, hash)
+		}
+	}
+	s.challenges[challenge.Hash] = challenge
+	return nil
+}
+
+func (s *MemoryMfaChallengeStore) Take(ctx context.Context, hash string) (*model.MfaChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	challenge, ok := s.challenges[hash]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.challenges, hash)
+	return &challenge, nil
+}
@@ -23,8 +23,19 @@ func (m *MockUserService) GetUser(ctx context.Context, username, password string
 	return m.response, m.err
 }
 
+// GetUserById returns the configured response when its id matches.
+func (m *MockUserService) GetUserById(ctx context.Context, id string) (*model.GetUserResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil || m.response == nil || m.response.Id != id {
+		return nil, m.err
+	}
+	return m.response, nil
+}
+
 func (m *MockUserService) SetGetUserResponse(response *model.GetUserResponse, err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.response = response
+	m.err = err
 }
@@ -0,0 +1,140 @@
+package driven
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+
+	// wildcard matches any role, action or resource type in a rule.
+	wildcard = "*"
+)
+
+// PolicyRule grants or denies Actions on resources of the given Types to
+// subjects with one of Roles. The remaining fields are conditions that must
+// all hold for the rule to match.
+type PolicyRule struct {
+	Name      string   `yaml:"name"`
+	Effect    string   `yaml:"effect"`
+	Roles     []string `yaml:"roles"`
+	Actions   []string `yaml:"actions"`
+	Resources []string `yaml:"resources"`
+	// Admin requires the subject to be an admin.
+	Admin bool `yaml:"admin"`
+	// Scope requires the token to have been granted this scope.
+	Scope string `yaml:"scope"`
+	// Owner requires the subject to own the resource.
+	Owner bool `yaml:"owner"`
+	// Attributes must all be present on the resource with these values.
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// RulePolicyEngine decides permission checks with an ordered list of rules.
+// A matching deny rule wins over any allow rule; among rules of the same
+// effect the first match is reported. Requests no rule matches are denied.
+type RulePolicyEngine struct {
+	rules []PolicyRule
+}
+
+func NewRulePolicyEngine(rules ...PolicyRule) (*RulePolicyEngine, error) {
+	names := map[string]bool{}
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("policy rule has no name")
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate policy rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return nil, fmt.Errorf("policy rule %s: effect must be %q or %q", rule.Name, EffectAllow, EffectDeny)
+		}
+		if len(rule.Roles) == 0 || len(rule.Actions) == 0 || len(rule.Resources) == 0 {
+			return nil, fmt.Errorf("policy rule %s: roles, actions and resources are required", rule.Name)
+		}
+	}
+	return &RulePolicyEngine{rules: rules}, nil
+}
+
+// LoadPolicyEngine reads the rules of a policy file, the same YAML file
+// LoadRolePolicy reads the roles from:
+//
+//	rules:
+//	  - name: owners-read-transactions
+//	    effect: allow
+//	    roles: ["*"]
+//	    actions: [read]
+//	    resources: [transaction]
+//	    owner: true
+func LoadPolicyEngine(path string) (*RulePolicyEngine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []PolicyRule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return NewRulePolicyEngine(file.Rules...)
+}
+
+func (e *RulePolicyEngine) Evaluate(ctx context.Context, request model.AuthorizationRequest) (model.Decision, error) {
+	var allowed *PolicyRule
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.matches(request) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return model.Decision{Allowed: false, Rule: rule.Name}, nil
+		}
+		if allowed == nil {
+			allowed = rule
+		}
+	}
+	if allowed == nil {
+		return model.Decision{}, nil
+	}
+	return model.Decision{Allowed: true, Rule: allowed.Name}, nil
+}
+
+func (r *PolicyRule) matches(request model.AuthorizationRequest) bool {
+	subject, resource := request.Subject, request.Resource
+	if !matchesAny(r.Roles, subject.RoleId) || !matchesAny(r.Actions, request.Action) || !matchesAny(r.Resources, resource.Type) {
+		return false
+	}
+	if r.Admin && !subject.IsAdmin {
+		return false
+	}
+	if r.Scope != "" && !model.HasScope(subject.Scopes, r.Scope) {
+		return false
+	}
+	if r.Owner && (subject.UserId == "" || subject.UserId != resource.OwnerId) {
+		return false
+	}
+	for key, value := range r.Attributes {
+		if actual, ok := resource.Attributes[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether value is in patterns or patterns has wildcard.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == wildcard || pattern == value {
+			return true
+		}
+	}
+	return false
+}
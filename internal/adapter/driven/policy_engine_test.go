@@ -0,0 +1,95 @@
+package driven
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRulePolicyEngine_Evaluate(t *testing.T) {
+	engine, err := NewRulePolicyEngine(
+		PolicyRule{Name: "admins", Effect: EffectAllow, Roles: []string{"*"}, Actions: []string{"*"}, Resources: []string{"*"}, Admin: true},
+		PolicyRule{Name: "owners-read", Effect: EffectAllow, Roles: []string{"*"}, Actions: []string{"read"}, Resources: []string{"transaction"}, Owner: true},
+		PolicyRule{Name: "accountants-write", Effect: EffectAllow, Roles: []string{"accountant"}, Actions: []string{"read", "write"}, Resources: []string{"transaction"}, Scope: "transactions:write"},
+		PolicyRule{Name: "frozen", Effect: EffectDeny, Roles: []string{"*"}, Actions: []string{"write"}, Resources: []string{"*"}, Attributes: map[string]string{"status": "frozen"}},
+	)
+	assert.NoError(t, err)
+
+	owner := model.Subject{UserId: "1", RoleId: "member"}
+	accountant := model.Subject{UserId: "2", RoleId: "accountant", Scopes: []string{"transactions:write"}}
+	admin := model.Subject{UserId: "3", IsAdmin: true}
+	transaction := model.Resource{Type: "transaction", Id: "t-1", OwnerId: "1"}
+	frozen := model.Resource{Type: "transaction", Id: "t-2", OwnerId: "1", Attributes: map[string]string{"status": "frozen"}}
+
+	tests := []struct {
+		name     string
+		subject  model.Subject
+		action   string
+		resource model.Resource
+		expected model.Decision
+	}{
+		{"owner reads own", owner, "read", transaction, model.Decision{Allowed: true, Rule: "owners-read"}},
+		{"owner cannot write", owner, "write", transaction, model.Decision{}},
+		{"other user cannot read", model.Subject{UserId: "9", RoleId: "member"}, "read", transaction, model.Decision{}},
+		{"accountant writes", accountant, "write", transaction, model.Decision{Allowed: true, Rule: "accountants-write"}},
+		{"accountant without scope", model.Subject{UserId: "2", RoleId: "accountant"}, "write", transaction, model.Decision{}},
+		{"admin deletes", admin, "delete", transaction, model.Decision{Allowed: true, Rule: "admins"}},
+		{"deny wins over allow", admin, "write", frozen, model.Decision{Rule: "frozen"}},
+		{"client without owner", model.Subject{ClientId: "reports"}, "read", model.Resource{Type: "transaction"}, model.Decision{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate(context.Background(), model.AuthorizationRequest{Subject: tt.subject, Action: tt.action, Resource: tt.resource})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, decision)
+		})
+	}
+}
+
+func TestNewRulePolicyEngine_Invalid(t *testing.T) {
+	valid := PolicyRule{Name: "r", Effect: EffectAllow, Roles: []string{"*"}, Actions: []string{"read"}, Resources: []string{"*"}}
+
+	_, err := NewRulePolicyEngine(valid, valid)
+	assert.ErrorContains(t, err, "duplicate")
+
+	invalid := valid
+	invalid.Effect = "maybe"
+	_, err = NewRulePolicyEngine(invalid)
+	assert.Error(t, err)
+
+	invalid = valid
+	invalid.Actions = nil
+	_, err = NewRulePolicyEngine(invalid)
+	assert.Error(t, err)
+}
+
+func TestLoadPolicyEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	err := os.WriteFile(path, []byte(`
+roles:
+  member:
+    permissions: [transactions:read]
+rules:
+  - name: owners-read
+    effect: allow
+    roles: ["*"]
+    actions: [read]
+    resources: [transaction]
+    owner: true
+`), 0o600)
+	assert.NoError(t, err)
+
+	engine, err := LoadPolicyEngine(path)
+	assert.NoError(t, err)
+	decision, err := engine.Evaluate(context.Background(), model.AuthorizationRequest{
+		Subject:  model.Subject{UserId: "1"},
+		Action:   "read",
+		Resource: model.Resource{Type: "transaction", OwnerId: "1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Decision{Allowed: true, Rule: "owners-read"}, decision)
+}
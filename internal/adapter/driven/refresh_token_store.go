@@ -0,0 +1,98 @@
+package driven
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// MemoryRefreshTokenStore keeps refresh tokens in process memory.
+type MemoryRefreshTokenStore struct {
+	mu              sync.Mutex
+	tokens          map[string]model.RefreshToken
+	revokedFamilies map[string]time.Time
+}
+
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		tokens:          map[string]model.RefreshToken{},
+		revokedFamilies: map[string]time.Time{},
+	}
+}
+
+func (s *MemoryRefreshTokenStore) Save(ctx context.Context, token model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	s.tokens[token.Hash] = token
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) Get(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[hash]
+	if !ok {
+		return nil, nil
+	}
+	if _, revoked := s.revokedFamilies[token.FamilyId]; revoked {
+		token.Revoked = true
+	}
+	return &token, nil
+}
+
+func (s *MemoryRefreshTokenStore) MarkRotated(ctx context.Context, hash string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[hash]
+	if !ok || token.IsRotated() {
+		return false, nil
+	}
+	token.RotatedAt = at
+	s.tokens[hash] = token
+	return true, nil
+}
+
+func (s *MemoryRefreshTokenStore) RevokeFamily(ctx context.Context, familyId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeFamily(familyId)
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) RevokeUser(ctx context.Context, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.tokens {
+		if token.Subject.UserId == userId {
+			s.revokeFamily(token.FamilyId)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) revokeFamily(familyId string) {
+	var expiresAt time.Time
+	for _, token := range s.tokens {
+		if token.FamilyId == familyId && token.ExpiresAt.After(expiresAt) {
+			expiresAt = token.ExpiresAt
+		}
+	}
+	s.revokedFamilies[familyId] = expiresAt
+}
+
+// prune drops expired tokens and families whose last token has expired.
+func (s *MemoryRefreshTokenStore) prune(now time.Time) {
+	for hash, token := range s.tokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(s.tokens, hash)
+		}
+	}
+	for familyId, expiresAt := range s.revokedFamilies {
+		if !now.Before(expiresAt) {
+			delete(s.revokedFamilies, familyId)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package driven
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/driven"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ResilienceConfig configures a ResilientUserService. A zero value disables
+// the corresponding protection.
+type ResilienceConfig struct {
+	// Timeout bounds every attempt of a call.
+	Timeout time.Duration
+	// MaxAttempts is how often a call is tried in total; retries only follow
+	// transient failures. Values below 2 disable retries.
+	MaxAttempts int
+	// The wait before retry n is drawn at random from zero up to BaseBackoff
+	// doubled n-1 times, capped at MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// FailureThreshold transient failures in a row open the circuit, which
+	// then fails calls right away for OpenDuration before letting one probe.
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+// ResilientUserService wraps a user service with per-attempt timeouts,
+// retries with jittered backoff and a circuit breaker. Only failures that say
+// nothing about the request itself open the circuit: Unavailable, Aborted and
+// attempts running out of time while the caller still waits. GetUserById
+// retries all of them. GetUser checks credentials, and a timed out attempt
+// may still have been counted by the user service, so it is only retried
+// when the user service could not be reached.
+type ResilientUserService struct {
+	next    driven.UserService
+	config  ResilienceConfig
+	metrics driven.Metrics
+	now     func() time.Time
+	jitter  func(max time.Duration) time.Duration
+
+	mu       sync.Mutex
+	state    model.CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// ResilienceOption configures optional behaviour of a ResilientUserService.
+type ResilienceOption func(*ResilientUserService)
+
+// WithResilienceMetrics counts retries and records the circuit state in metrics.
+func WithResilienceMetrics(metrics driven.Metrics) ResilienceOption {
+	return func(rs *ResilientUserService) {
+		rs.metrics = metrics
+	}
+}
+
+// WithResilienceClock replaces time.Now, mainly so tests can be deterministic.
+func WithResilienceClock(now func() time.Time) ResilienceOption {
+	return func(rs *ResilientUserService) {
+		rs.now = now
+	}
+}
+
+func NewResilientUserService(next driven.UserService, config ResilienceConfig, opts ...ResilienceOption) *ResilientUserService {
+	rs := &ResilientUserService{
+		next:   next,
+		config: config,
+		now:    time.Now,
+		jitter: func(max time.Duration) time.Duration { return rand.N(max + 1) },
+		state:  model.CircuitClosed,
+	}
+	for _, opt := range opts {
+		opt(rs)
+	}
+	return rs
+}
+
+func (rs *ResilientUserService) GetUser(ctx context.Context, username, password string) (*model.GetUserResponse, error) {
+	var resp *model.GetUserResponse
+	err := rs.call(ctx, "GetUser", isUnavailable, func(ctx context.Context) error {
+		var err error
+		resp, err = rs.next.GetUser(ctx, username, password)
+		return err
+	})
+	return resp, err
+}
+
+func (rs *ResilientUserService) GetUserById(ctx context.Context, id string) (*model.GetUserResponse, error) {
+	var resp *model.GetUserResponse
+	err := rs.call(ctx, "GetUserById", isTransient, func(ctx context.Context) error {
+		var err error
+		resp, err = rs.next.GetUserById(ctx, id)
+		return err
+	})
+	return resp, err
+}
+
+// State returns the state of the circuit.
+func (rs *ResilientUserService) State() model.CircuitState {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.state
+}
+
+// Check fails while the circuit is open and calls to the user service fail
+// fast. It passes again once a probe is due, as the probe needs a call.
+func (rs *ResilientUserService) Check(ctx context.Context) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.state == model.CircuitOpen {
+		if until := rs.openedAt.Add(rs.config.OpenDuration); rs.now().Before(until) {
+			return fmt.Errorf("circuit is open until %s", until.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// call runs fn until it succeeds, fails with an error retryable rejects or
+// runs out of attempts.
+func (rs *ResilientUserService) call(ctx context.Context, method string, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		if !rs.allow() {
+			return fmt.Errorf("%w: circuit is open", domain.ErrUserServiceUnavailable)
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if rs.config.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, rs.config.Timeout)
+		}
+		err := fn(attemptCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the user service.
+			rs.release()
+			return err
+		}
+		if !isTransient(err) {
+			rs.succeeded()
+			return err
+		}
+		rs.failed()
+
+		if attempt >= rs.config.MaxAttempts || !retryable(err) {
+			return err
+		}
+		slog.WarnContext(ctx, "Retrying user service call", "method", method, "attempt", attempt, "error", err)
+		if rs.metrics != nil {
+			rs.metrics.CountUserServiceRetry(method)
+		}
+		if !sleep(ctx, rs.backoff(attempt)) {
+			return err
+		}
+	}
+}
+
+// isTransient reports whether err is a failure of the user service or the
+// network rather than an answer to the request.
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return errors.Is(err, domain.ErrUserServiceUnavailable)
+}
+
+// isUnavailable reports whether err says the user service could not be
+// reached, so the request never got to it.
+func isUnavailable(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// backoff returns the wait before retrying after attempt failed.
+func (rs *ResilientUserService) backoff(attempt int) time.Duration {
+	if rs.config.BaseBackoff <= 0 {
+		return 0
+	}
+	limit := rs.config.BaseBackoff
+	for i := 1; i < attempt && (rs.config.MaxBackoff <= 0 || limit < rs.config.MaxBackoff); i++ {
+		limit *= 2
+	}
+	if rs.config.MaxBackoff > 0 && limit > rs.config.MaxBackoff {
+		limit = rs.config.MaxBackoff
+	}
+	return rs.jitter(limit)
+}
+
+// sleep waits for d and reports false when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// allow reports whether a call may be made. Once the open circuit has waited
+// OpenDuration, a single call is let through as a probe.
+func (rs *ResilientUserService) allow() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	switch rs.state {
+	case model.CircuitOpen:
+		if rs.now().Before(rs.openedAt.Add(rs.config.OpenDuration)) {
+			return false
+		}
+		rs.setState(model.CircuitHalfOpen)
+		rs.probing = true
+		return true
+	case model.CircuitHalfOpen:
+		if rs.probing {
+			return false
+		}
+		rs.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (rs *ResilientUserService) succeeded() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.failures = 0
+	rs.probing = false
+	if rs.state != model.CircuitClosed {
+		slog.Info("User service recovered, closing the circuit")
+		rs.setState(model.CircuitClosed)
+	}
+}
+
+func (rs *ResilientUserService) failed() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.failures++
+	rs.probing = false
+	if rs.config.FailureThreshold <= 0 {
+		return
+	}
+	if rs.state == model.CircuitHalfOpen || rs.failures >= rs.config.FailureThreshold {
+		if rs.state != model.CircuitOpen {
+			slog.Warn("User service keeps failing, opening the circuit", "failures", rs.failures, "open_for", rs.config.OpenDuration.String())
+		}
+		rs.openedAt = rs.now()
+		rs.setState(model.CircuitOpen)
+	}
+}
+
+// release frees the probe slot of a call abandoned by its caller.
+func (rs *ResilientUserService) release() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.probing = false
+}
+
+// setState changes the state of the circuit. Callers must hold the lock.
+func (rs *ResilientUserService) setState(state model.CircuitState) {
+	rs.state = state
+	if rs.metrics != nil {
+		rs.metrics.SetUserServiceCircuitState(state)
+	}
+}
@@ -0,0 +1,172 @@
+package driven
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyUserService fails with the queued errors before answering.
+type flakyUserService struct {
+	mu        sync.Mutex
+	errs      []error
+	calls     int
+	deadlines []bool
+}
+
+func (f *flakyUserService) GetUser(ctx context.Context, username, password string) (*model.GetUserResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	_, hasDeadline := ctx.Deadline()
+	f.deadlines = append(f.deadlines, hasDeadline)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &model.GetUserResponse{Id: "u1", Username: username}, nil
+}
+
+func (f *flakyUserService) GetUserById(ctx context.Context, id string) (*model.GetUserResponse, error) {
+	return f.GetUser(ctx, id, "")
+}
+
+func (f *flakyUserService) fail(errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs = errs
+	f.calls = 0
+}
+
+var unavailable = status.Error(codes.Unavailable, "connection refused")
+
+func TestResilientUserService_Retries(t *testing.T) {
+	ctx := context.Background()
+	next := &flakyUserService{}
+	metrics := NewMemoryMetrics()
+	rs := NewResilientUserService(next, ResilienceConfig{Timeout: time.Second, MaxAttempts: 3, BaseBackoff: time.Millisecond}, WithResilienceMetrics(metrics))
+
+	// Transient failures are retried with a deadline on every attempt.
+	timedOut := toUserServiceError(status.Error(codes.DeadlineExceeded, "slow"))
+	next.fail(unavailable, timedOut)
+	user, err := rs.GetUserById(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", user.Id)
+	assert.Equal(t, 3, next.calls)
+	assert.Equal(t, []bool{true, true, true}, next.deadlines)
+	assert.Equal(t, 2, metrics.UserServiceRetries("GetUserById"))
+
+	// Credential checks are only retried when the user service was not reached.
+	next.fail(toUserServiceError(unavailable), unavailable)
+	_, err = rs.GetUser(ctx, "bob", "pw")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, next.calls)
+	next.fail(timedOut)
+	_, err = rs.GetUser(ctx, "bob", "pw")
+	assert.ErrorIs(t, err, domain.ErrUserServiceUnavailable)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, 1, next.calls)
+	assert.Equal(t, 2, metrics.UserServiceRetries("GetUser"))
+
+	// Answers to the request are not.
+	next.fail(status.Error(codes.InvalidArgument, "bad request"))
+	_, err = rs.GetUserById(ctx, "u1")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 1, next.calls)
+
+	// Attempts are limited.
+	next.fail(unavailable, unavailable, unavailable, unavailable)
+	_, err = rs.GetUser(ctx, "bob", "pw")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 3, next.calls)
+
+	// A caller that gives up stops the retries.
+	next.fail(unavailable, unavailable, unavailable)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = rs.GetUser(cancelled, "bob", "pw")
+	assert.Error(t, err)
+	assert.Equal(t, 1, next.calls)
+}
+
+func TestResilientUserService_Backoff(t *testing.T) {
+	rs := NewResilientUserService(&flakyUserService{}, ResilienceConfig{BaseBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond})
+	rs.jitter = func(max time.Duration) time.Duration { return max }
+	assert.Equal(t, 100*time.Millisecond, rs.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, rs.backoff(2))
+	assert.Equal(t, 300*time.Millisecond, rs.backoff(3))
+	assert.Equal(t, 300*time.Millisecond, rs.backoff(10))
+}
+
+func TestResilientUserService_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+	next := &flakyUserService{}
+	metrics := NewMemoryMetrics()
+	rs := NewResilientUserService(next, ResilienceConfig{FailureThreshold: 2, OpenDuration: 30 * time.Second},
+		WithResilienceMetrics(metrics), WithResilienceClock(clock))
+
+	next.fail(unavailable, unavailable)
+	_, _ = rs.GetUser(ctx, "bob", "pw")
+	assert.Equal(t, model.CircuitClosed, rs.State())
+	_, _ = rs.GetUser(ctx, "bob", "pw")
+	assert.Equal(t, model.CircuitOpen, rs.State())
+	assert.Equal(t, model.CircuitOpen, metrics.UserServiceCircuitState())
+	assert.Error(t, rs.Check(ctx))
+
+	// The open circuit fails fast without calling the user service.
+	next.fail()
+	_, err := rs.GetUser(ctx, "bob", "pw")
+	assert.ErrorIs(t, err, domain.ErrUserServiceUnavailable)
+	assert.Equal(t, 0, next.calls)
+
+	// After OpenDuration a failing probe opens it again.
+	now = now.Add(31 * time.Second)
+	assert.NoError(t, rs.Check(ctx), "a probe is due")
+	next.fail(unavailable)
+	_, err = rs.GetUser(ctx, "bob", "pw")
+	assert.Error(t, err)
+	assert.Equal(t, 1, next.calls)
+	assert.Equal(t, model.CircuitOpen, rs.State())
+
+	// A successful probe closes it.
+	now = now.Add(31 * time.Second)
+	next.fail()
+	_, err = rs.GetUser(ctx, "bob", "pw")
+	assert.NoError(t, err)
+	assert.Equal(t, model.CircuitClosed, rs.State())
+	assert.Equal(t, model.CircuitClosed, metrics.UserServiceCircuitState())
+
+	// Rejected credentials mean the service works; they never open the circuit.
+	next.fail(status.Error(codes.NotFound, "no user"), status.Error(codes.NotFound, "no user"), status.Error(codes.NotFound, "no user"))
+	for i := 0; i < 3; i++ {
+		_, _ = rs.GetUser(ctx, "bob", "wrong")
+	}
+	assert.Equal(t, model.CircuitClosed, rs.State())
+}
+
+func TestResilientUserService_HalfOpenAllowsOneProbe(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rs := NewResilientUserService(&flakyUserService{}, ResilienceConfig{FailureThreshold: 1, OpenDuration: time.Second},
+		WithResilienceClock(func() time.Time { return now }))
+	rs.failed()
+	assert.False(t, rs.allow())
+
+	now = now.Add(2 * time.Second)
+	assert.True(t, rs.allow())
+	assert.Equal(t, model.CircuitHalfOpen, rs.State())
+	assert.False(t, rs.allow(), "only one probe at a time")
+	rs.release()
+	assert.True(t, rs.allow(), "an abandoned probe frees the slot")
+}
@@ -0,0 +1,141 @@
+package driven
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	revokedTokensBucket = []byte("revoked_tokens")
+	revokedUsersBucket  = []byte("revoked_users")
+)
+
+// BoltRevocationStore persists revocations in a bbolt database file so they
+// survive restarts.
+type BoltRevocationStore struct {
+	db *bolt.DB
+}
+
+// NewBoltRevocationStore opens (or creates) the database at path.
+func NewBoltRevocationStore(path string) (*BoltRevocationStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(revokedTokensBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(revokedUsersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltRevocationStore{db: db}, nil
+}
+
+func (s *BoltRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(revokedTokensBucket)
+		// Drop entries whose tokens have expired on their own.
+		if err := pruneExpired(bucket, decodeUnix); err != nil {
+			return err
+		}
+		return bucket.Put([]byte(jti), encodeUnix(expiresAt))
+	})
+}
+
+func (s *BoltRevocationStore) IsRevoked(ctx context.Context, jti string) (revoked bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		revoked = tx.Bucket(revokedTokensBucket).Get([]byte(jti)) != nil
+		return nil
+	})
+	return
+}
+
+// RevokeAllForUser stores the cut-off followed by its expiry and the
+// nanoseconds of the cut-off.
+func (s *BoltRevocationStore) RevokeAllForUser(ctx context.Context, userId string, at, expiresAt time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(revokedUsersBucket)
+		if err := pruneExpired(bucket, cutoffExpiry); err != nil {
+			return err
+		}
+		value := append(encodeUnix(at), encodeUnix(expiresAt)...)
+		value = binary.BigEndian.AppendUint64(value, uint64(at.Nanosecond()))
+		return bucket.Put([]byte(userId), value)
+	})
+}
+
+func (s *BoltRevocationStore) RevokedBefore(ctx context.Context, userId string) (cutoff time.Time, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(revokedUsersBucket).Get([]byte(userId))
+		if v != nil && time.Now().Before(cutoffExpiry(v)) {
+			cutoff = decodeCutoff(v)
+		}
+		return nil
+	})
+	return
+}
+
+func (s *BoltRevocationStore) Close() error {
+	return s.db.Close()
+}
+
+// pruneExpired deletes the entries of bucket whose expiry, as read by
+// expiry, has passed.
+func pruneExpired(bucket *bolt.Bucket, expiry func([]byte) time.Time) error {
+	now := time.Now()
+	var expired [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		if !now.Before(expiry(v)) {
+			expired = append(expired, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cutoffExpiry reads the expiry of a cut-off. Cut-offs written before they
+// had one never expire.
+func cutoffExpiry(v []byte) time.Time {
+	if len(v) < 16 {
+		return time.Unix(1<<62, 0)
+	}
+	return decodeUnix(v[8:16])
+}
+
+// decodeCutoff reads the cut-off. Cut-offs written before the nanoseconds
+// were stored have second precision.
+func decodeCutoff(v []byte) time.Time {
+	at := decodeUnix(v[:8])
+	if len(v) >= 24 {
+		at = at.Add(time.Duration(binary.BigEndian.Uint64(v[16:24])))
+	}
+	return at
+}
+
+func encodeUnix(t time.Time) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(t.Unix()))
+	return buf
+}
+
+func decodeUnix(b []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+}
@@ -0,0 +1,72 @@
+package driven
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "revocation.db")
+
+	store, err := NewBoltRevocationStore(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Revoke(ctx, "jti-1", time.Now().Add(time.Hour)))
+	// Cut-offs keep their sub-second precision.
+	cutoff := time.Unix(time.Now().Unix(), 123456789)
+	assert.NoError(t, store.RevokeAllForUser(ctx, "user-1", cutoff, time.Now().Add(time.Hour)))
+	assert.NoError(t, store.Close())
+
+	// Revocations survive reopening the database.
+	store, err = NewBoltRevocationStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	revoked, err := store.IsRevoked(ctx, "jti-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "jti-2")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	before, err := store.RevokedBefore(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.True(t, cutoff.Equal(before))
+
+	before, err = store.RevokedBefore(ctx, "user-2")
+	assert.NoError(t, err)
+	assert.True(t, before.IsZero())
+}
+
+func TestBoltRevocationStore_PrunesExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltRevocationStore(filepath.Join(t.TempDir(), "revocation.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Revoke(ctx, "expired", time.Now().Add(-time.Minute)))
+	assert.NoError(t, store.Revoke(ctx, "live", time.Now().Add(time.Hour)))
+	assert.NoError(t, store.RevokeAllForUser(ctx, "expired", time.Now(), time.Now().Add(-time.Minute)))
+	assert.NoError(t, store.RevokeAllForUser(ctx, "live", time.Now(), time.Now().Add(time.Hour)))
+
+	before, err := store.RevokedBefore(ctx, "expired")
+	assert.NoError(t, err)
+	assert.True(t, before.IsZero())
+	before, err = store.RevokedBefore(ctx, "live")
+	assert.NoError(t, err)
+	assert.False(t, before.IsZero())
+
+	revoked, err := store.IsRevoked(ctx, "expired")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "live")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,71 @@
+package driven
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRevocationStore keeps revoked token ids in process memory, so
+// revocations are lost on restart and not shared between replicas.
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	tokens  map[string]time.Time
+	cutoffs map[string]cutoff
+}
+
+// cutoff is a RevokeAllForUser entry.
+type cutoff struct {
+	at        time.Time
+	expiresAt time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens:  map[string]time.Time{},
+		cutoffs: map[string]cutoff{},
+	}
+}
+
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, exp := range s.tokens {
+		if !now.Before(exp) {
+			delete(s.tokens, id)
+		}
+	}
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tokens[jti]
+	return ok, nil
+}
+
+func (s *MemoryRevocationStore) RevokeAllForUser(ctx context.Context, userId string, at, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, c := range s.cutoffs {
+		if !now.Before(c.expiresAt) {
+			delete(s.cutoffs, id)
+		}
+	}
+	s.cutoffs[userId] = cutoff{at: at, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryRevocationStore) RevokedBefore(ctx context.Context, userId string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cutoffs[userId]
+	if !ok || !time.Now().Before(c.expiresAt) {
+		return time.Time{}, nil
+	}
+	return c.at, nil
+}
@@ -0,0 +1,50 @@
+package driven
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MemoryRolePolicy maps role ids to the permissions they grant.
+type MemoryRolePolicy struct {
+	roles map[string][]string
+}
+
+func NewMemoryRolePolicy(roles map[string][]string) *MemoryRolePolicy {
+	return &MemoryRolePolicy{roles: roles}
+}
+
+// rolePolicyFile is the YAML layout read by LoadRolePolicy:
+//
+//	roles:
+//	  "<role id>":
+//	    permissions: [transaction:read, transaction:write]
+type rolePolicyFile struct {
+	Roles map[string]struct {
+		Permissions []string `yaml:"permissions"`
+	} `yaml:"roles"`
+}
+
+// LoadRolePolicy reads a role policy from a YAML file.
+func LoadRolePolicy(path string) (*MemoryRolePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file rolePolicyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid role policy %s: %w", path, err)
+	}
+	roles := make(map[string][]string, len(file.Roles))
+	for id, role := range file.Roles {
+		roles[id] = role.Permissions
+	}
+	return NewMemoryRolePolicy(roles), nil
+}
+
+func (p *MemoryRolePolicy) Permissions(ctx context.Context, roleId string) ([]string, error) {
+	return append([]string(nil), p.roles[roleId]...), nil
+}
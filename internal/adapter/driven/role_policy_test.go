@@ -0,0 +1,39 @@
+package driven
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRolePolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roles.yaml")
+	err := os.WriteFile(path, []byte(`
+roles:
+  member:
+    permissions: [transactions:read]
+  accountant:
+    permissions:
+      - transactions:read
+      - transactions:write
+`), 0o600)
+	assert.NoError(t, err)
+
+	policy, err := LoadRolePolicy(path)
+	assert.NoError(t, err)
+
+	permissions, err := policy.Permissions(context.Background(), "accountant")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"transactions:read", "transactions:write"}, permissions)
+
+	permissions, err = policy.Permissions(context.Background(), "unknown")
+	assert.NoError(t, err)
+	assert.Empty(t, permissions)
+
+	assert.NoError(t, os.WriteFile(path, []byte("roles: [broken"), 0o600))
+	_, err = LoadRolePolicy(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,207 @@
+package driven
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Supported values for the signing algorithm of a SigningKey.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var errVerifyOnlyKey = errors.New("signing key has no private part")
+
+// SigningKey pairs a JWT signing method with the keys used to sign and verify
+// tokens. A key loaded from a public key only has a nil PrivateKey and can
+// verify tokens but not create them. Id is stamped as the "kid" header.
+type SigningKey struct {
+	Id         string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+// NewHMACKey creates an HS256 key from a shared secret.
+func NewHMACKey(secret string) SigningKey {
+	return withThumbprint(SigningKey{Method: jwt.SigningMethodHS256, PrivateKey: []byte(secret), PublicKey: []byte(secret)})
+}
+
+// WithId returns a copy of the key that uses id as its kid.
+func (k SigningKey) WithId(id string) SigningKey {
+	k.Id = id
+	return k
+}
+
+// CanSign reports whether the key holds the private part needed to sign tokens.
+func (k SigningKey) CanSign() bool {
+	return k.PrivateKey != nil
+}
+
+// Algorithm returns the JWT "alg" header value of the key.
+func (k SigningKey) Algorithm() string {
+	return k.Method.Alg()
+}
+
+// LoadSigningKey reads a PEM encoded private key for alg from path. alg must
+// be one of RS256, ES256 or EdDSA.
+func LoadSigningKey(alg, path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return ParseSigningKey(alg, data)
+}
+
+// ParseSigningKey parses a PEM encoded private key for alg.
+func ParseSigningKey(alg string, data []byte) (SigningKey, error) {
+	switch alg {
+	case AlgorithmRS256:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return withThumbprint(SigningKey{Method: jwt.SigningMethodRS256, PrivateKey: key, PublicKey: &key.PublicKey}), nil
+	case AlgorithmES256:
+		key, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		if err := checkCurve(&key.PublicKey); err != nil {
+			return SigningKey{}, err
+		}
+		return withThumbprint(SigningKey{Method: jwt.SigningMethodES256, PrivateKey: key, PublicKey: &key.PublicKey}), nil
+	case AlgorithmEdDSA:
+		parsed, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return SigningKey{}, jwt.ErrNotEdPrivateKey
+		}
+		return withThumbprint(SigningKey{Method: jwt.SigningMethodEdDSA, PrivateKey: key, PublicKey: key.Public()}), nil
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported asymmetric signing algorithm %q", alg)
+	}
+}
+
+// GenerateSigningKey creates a fresh random key for alg.
+func GenerateSigningKey(alg string) (SigningKey, error) {
+	var private interface{}
+	switch alg {
+	case AlgorithmHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return SigningKey{}, err
+		}
+		return NewHMACKey(base64.RawURLEncoding.EncodeToString(secret)), nil
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		private = key
+	case AlgorithmES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		private = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		private = key
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	// Round trip through PEM so generated keys are built exactly like loaded ones.
+	data, err := encodePrivateKey(private)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return ParseSigningKey(alg, data)
+}
+
+// encodePrivateKey returns the PKCS #8 PEM encoding of an asymmetric private key.
+func encodePrivateKey(key interface{}) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// LoadVerificationKey reads a PEM encoded public key for alg from path. The
+// resulting key can only verify tokens.
+func LoadVerificationKey(alg, path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return ParseVerificationKey(alg, data)
+}
+
+// ParseVerificationKey parses a PEM encoded public key for alg.
+func ParseVerificationKey(alg string, data []byte) (SigningKey, error) {
+	switch alg {
+	case AlgorithmRS256:
+		key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return withThumbprint(SigningKey{Method: jwt.SigningMethodRS256, PublicKey: key}), nil
+	case AlgorithmES256:
+		key, err := jwt.ParseECPublicKeyFromPEM(data)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		if err := checkCurve(key); err != nil {
+			return SigningKey{}, err
+		}
+		return withThumbprint(SigningKey{Method: jwt.SigningMethodES256, PublicKey: key}), nil
+	case AlgorithmEdDSA:
+		parsed, err := jwt.ParseEdPublicKeyFromPEM(data)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		key, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return SigningKey{}, jwt.ErrNotEdPublicKey
+		}
+		return withThumbprint(SigningKey{Method: jwt.SigningMethodEdDSA, PublicKey: key}), nil
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported asymmetric signing algorithm %q", alg)
+	}
+}
+
+// withThumbprint sets the default kid of key.
+func withThumbprint(key SigningKey) SigningKey {
+	key.Id = key.thumbprint()
+	return key
+}
+
+// checkCurve makes sure an ES256 key is on P-256, as the algorithm requires.
+func checkCurve(key *ecdsa.PublicKey) error {
+	if key.Curve != elliptic.P256() {
+		return fmt.Errorf("ES256 requires a P-256 key, got %s", key.Curve.Params().Name)
+	}
+	return nil
+}
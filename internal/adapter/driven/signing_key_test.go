@@ -0,0 +1,119 @@
+package driven
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeKeyPair stores a freshly generated key pair for alg as PEM files and
+// returns their paths.
+func writeKeyPair(t *testing.T, alg string) (privatePath, publicPath string) {
+	t.Helper()
+
+	var private, public interface{}
+	switch alg {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+		private, public = key, &key.PublicKey
+	case AlgorithmES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		private, public = key, &key.PublicKey
+	case AlgorithmEdDSA:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		private, public = key, pub
+	}
+
+	privateDer, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(public)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	privatePath = filepath.Join(dir, "private.pem")
+	publicPath = filepath.Join(dir, "public.pem")
+	assert.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}), 0o600))
+	assert.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer}), 0o600))
+	return privatePath, publicPath
+}
+
+func TestTokenService_AsymmetricSigning(t *testing.T) {
+	for _, alg := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			privatePath, publicPath := writeKeyPair(t, alg)
+
+			signingKey, err := LoadSigningKey(alg, privatePath)
+			assert.NoError(t, err)
+			assert.True(t, signingKey.CanSign())
+			assert.Equal(t, alg, signingKey.Algorithm())
+
+			verificationKey, err := LoadVerificationKey(alg, publicPath)
+			assert.NoError(t, err)
+			assert.False(t, verificationKey.CanSign())
+
+			signer := NewTokenServiceWithKey(signingKey, time.Hour)
+			subject := model.Subject{UserId: uuid.New().String(), IsAdmin: true}
+			token, err := signer.CreateToken(subject)
+			assert.NoError(t, err)
+
+			verifier := NewTokenServiceWithKey(verificationKey, time.Hour)
+			claims, err := verifier.GetToken(token)
+			assert.NoError(t, err)
+			sub, err := verifier.GetSubject(claims.Subject)
+			assert.NoError(t, err)
+			assert.Equal(t, subject, sub)
+
+			_, err = verifier.CreateToken(subject)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTokenService_RejectsOtherAlgorithms(t *testing.T) {
+	_, publicPath := writeKeyPair(t, AlgorithmRS256)
+	verificationKey, err := LoadVerificationKey(AlgorithmRS256, publicPath)
+	assert.NoError(t, err)
+
+	// An HS256 token signed with the public key bytes must not pass an RS256 verifier.
+	publicPem, err := os.ReadFile(publicPath)
+	assert.NoError(t, err)
+	forgingKey := NewHMACKey(string(publicPem)).WithId(verificationKey.Id)
+	forged, err := NewTokenServiceWithKey(forgingKey, time.Hour).CreateToken(model.Subject{UserId: "attacker", IsAdmin: true})
+	assert.NoError(t, err)
+
+	valid, err := NewTokenServiceWithKey(verificationKey, time.Hour).CheckToken(forged)
+	assert.ErrorIs(t, err, domain.ErrTokenInvalid)
+	assert.False(t, valid)
+}
+
+func TestParseSigningKey_Invalid(t *testing.T) {
+	_, err := ParseSigningKey("none", nil)
+	assert.Error(t, err)
+
+	privatePath, _ := writeKeyPair(t, AlgorithmEdDSA)
+	_, err = LoadSigningKey(AlgorithmRS256, privatePath)
+	assert.Error(t, err)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(p384)
+	assert.NoError(t, err)
+	_, err = ParseSigningKey(AlgorithmES256, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package driven
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTotp_Verify(t *testing.T) {
+	// The SHA-1 test vectors of RFC 6238 appendix B, truncated to 6 digits.
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		now := time.Unix(tt.unix, 0)
+		totp := NewTotp("Finman")
+		totp.now = func() time.Time { return now }
+
+		step, ok := totp.Verify(secret, tt.code)
+		assert.True(t, ok, tt.code)
+		assert.Equal(t, tt.unix/30, step)
+
+		// One step of clock skew is tolerated, two are not.
+		now = now.Add(30 * time.Second)
+		_, ok = totp.Verify(secret, tt.code)
+		assert.True(t, ok, tt.code)
+		now = now.Add(30 * time.Second)
+		_, ok = totp.Verify(secret, tt.code)
+		assert.False(t, ok, tt.code)
+	}
+
+	totp := NewTotp("Finman")
+	_, ok := totp.Verify(secret, "12345")
+	assert.False(t, ok)
+	_, ok = totp.Verify("not base32!", "123456")
+	assert.False(t, ok)
+}
+
+func TestTotp_Uri(t *testing.T) {
+	totp := NewTotp("Finman")
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri, err := url.Parse(totp.Uri(secret, "alice"))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Finman:alice", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "Finman", uri.Query().Get("issuer"))
+}
@@ -2,10 +2,15 @@ package driven
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	userv1 "github.com/nullexp/finman-auth-service/internal/adapter/driver/grpc/proto/user/v1"
+	"github.com/nullexp/finman-auth-service/internal/domain"
 	"github.com/nullexp/finman-auth-service/internal/port/model"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type UserService struct {
@@ -26,11 +31,47 @@ func (us *UserService) GetUser(ctx context.Context, username, password string) (
 
 	resp, err := us.client.GetUserByUsernameAndPassword(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, toUserServiceError(err)
 	}
 
+	return toGetUserResponse(resp.User), nil
+}
+
+func (us *UserService) GetUserById(ctx context.Context, id string) (*model.GetUserResponse, error) {
+	resp, err := us.client.GetUserById(ctx, &userv1.GetUserByIdRequest{Id: id})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toUserServiceError(err)
+	}
+
+	return toGetUserResponse(resp.User), nil
+}
+
+// toUserServiceError reports transport failures as ErrUserServiceUnavailable
+// so callers can tell an outage apart from a rejected request. The status is
+// kept so the kind of failure can still be told apart.
+func toUserServiceError(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", domain.ErrUserServiceUnavailable, err)
+	default:
+		return err
+	}
+}
+
+func toGetUserResponse(user *userv1.User) *model.GetUserResponse {
+	if user == nil {
+		return nil
+	}
+	// The user service sends RFC 3339 timestamps; anything else is left zero.
+	updatedAt, _ := time.Parse(time.RFC3339, user.UpdatedAt)
 	return &model.GetUserResponse{
-		Id:      resp.User.Id,
-		IsAdmin: resp.User.IsAdmin,
-	}, nil
+		Id:        user.Id,
+		IsAdmin:   user.IsAdmin,
+		RoleId:    user.RoleId,
+		Username:  user.Username,
+		UpdatedAt: updatedAt,
+	}
 }
@@ -0,0 +1,21 @@
+package driven
+
+import (
+	"context"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/driven"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UserServiceMetricsInterceptor records the latency and status of every call
+// made on the user service connection.
+func UserServiceMetricsInterceptor(metrics driven.Metrics) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		metrics.ObserveUserServiceCall(method, status.Code(err).String(), time.Since(start))
+		return err
+	}
+}
@@ -2,30 +2,278 @@ package grpc
 
 import (
 	"context"
-	"log"
+	"time"
 
 	authv1 "github.com/nullexp/finman-auth-service/internal/adapter/driver/grpc/proto/auth/v1"
 	"github.com/nullexp/finman-auth-service/internal/port/driver"
 	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type AuthService struct {
 	authv1.UnimplementedAuthServiceServer
-	service driver.AuthService
+	service           driver.AuthService
+	trustForwardedFor bool
 }
 
-func NewAuthService(as driver.AuthService) *AuthService {
-	return &AuthService{service: as}
+// Option configures optional behaviour of an AuthService.
+type Option func(*AuthService)
+
+// WithTrustForwardedFor takes the client address from x-forwarded-for
+// metadata instead of the connection. Only use it behind a trusted proxy.
+func WithTrustForwardedFor() Option {
+	return func(as *AuthService) {
+		as.trustForwardedFor = true
+	}
+}
+
+func NewAuthService(as driver.AuthService, opts ...Option) *AuthService {
+	service := &AuthService{service: as}
+	for _, opt := range opts {
+		opt(service)
+	}
+	return service
 }
 
 func (as AuthService) Login(ctx context.Context, req *authv1.LoginRequest) (*authv1.LoginResponse, error) {
-	log.Println("CALL: Login")
 	result, err := as.service.CreateToken(ctx, model.CreateTokenRequest{
 		Username: req.Username,
 		Password: req.Password,
+		Scopes:   req.Scopes,
+		ClientIp: clientIp(ctx, as.trustForwardedFor),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if result.MfaChallenge != "" {
+		return &authv1.LoginResponse{MfaRequired: true, MfaChallenge: result.MfaChallenge}, nil
+	}
+	return &authv1.LoginResponse{Token: result.Token, RefreshToken: result.RefreshToken, Scopes: result.Scopes}, nil
+}
+
+func (as AuthService) CompleteMfa(ctx context.Context, req *authv1.CompleteMfaRequest) (*authv1.CompleteMfaResponse, error) {
+	result, err := as.service.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: req.MfaChallenge, Code: req.Code, ClientIp: clientIp(ctx, as.trustForwardedFor)})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.CompleteMfaResponse{Token: result.Token, RefreshToken: result.RefreshToken}, nil
+}
+
+func (as AuthService) RefreshToken(ctx context.Context, req *authv1.RefreshTokenRequest) (*authv1.RefreshTokenResponse, error) {
+	result, err := as.service.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: req.RefreshToken})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.RefreshTokenResponse{Token: result.Token, RefreshToken: result.RefreshToken}, nil
+}
+
+func (as AuthService) ValidateToken(ctx context.Context, req *authv1.ValidateTokenRequest) (*authv1.ValidateTokenResponse, error) {
+	result, err := as.service.ValidateToken(ctx, model.ValidateTokenRequest{Token: req.Token})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if !result.Valid {
+		return &authv1.ValidateTokenResponse{Reason: toTokenInvalidReason(result.Reason)}, nil
+	}
+	return &authv1.ValidateTokenResponse{
+		Valid: true,
+		Subject: &authv1.Subject{
+			UserId:  result.Subject.UserId,
+			IsAdmin: result.Subject.IsAdmin,
+			RoleId:  result.Subject.RoleId,
+			Scopes:  result.Subject.Scopes,
+		},
+		Jti:       result.Identity,
+		IssuedAt:  toTimestamp(result.IssuedAt),
+		ExpiresAt: toTimestamp(result.ExpiresAt),
+	}, nil
+}
+
+func (as AuthService) CheckPermission(ctx context.Context, req *authv1.CheckPermissionRequest) (*authv1.CheckPermissionResponse, error) {
+	resource := model.Resource{
+		Type:       req.GetResource().GetType(),
+		Id:         req.GetResource().GetId(),
+		OwnerId:    req.GetResource().GetOwnerId(),
+		Attributes: req.GetResource().GetAttributes(),
+	}
+	result, err := as.service.CheckPermission(ctx, model.CheckPermissionRequest{Token: req.Token, Action: req.Action, Resource: resource})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.CheckPermissionResponse{Allowed: result.Allowed, Rule: result.Rule}, nil
+}
+
+func (as AuthService) GetJwks(ctx context.Context, req *authv1.GetJwksRequest) (*authv1.GetJwksResponse, error) {
+	result, err := as.service.GetJwks(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	keys := make([]*authv1.JsonWebKey, 0, len(result.Keys))
+	for _, key := range result.Keys {
+		keys = append(keys, &authv1.JsonWebKey{
+			Kty: key.Kty,
+			Use: key.Use,
+			Kid: key.Kid,
+			Alg: key.Alg,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+		})
+	}
+	return &authv1.GetJwksResponse{Keys: keys}, nil
+}
+
+func (as AuthService) Logout(ctx context.Context, req *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	err = as.service.Logout(ctx, model.LogoutRequest{Token: token, RefreshToken: req.RefreshToken})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.LogoutResponse{}, nil
+}
+
+func (as AuthService) RevokeToken(ctx context.Context, req *authv1.RevokeTokenRequest) (*authv1.RevokeTokenResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	err = as.service.RevokeToken(ctx, model.RevokeTokenRequest{Token: token, Identity: req.Jti})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.RevokeTokenResponse{}, nil
+}
+
+func (as AuthService) RevokeAllForUser(ctx context.Context, req *authv1.RevokeAllForUserRequest) (*authv1.RevokeAllForUserResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	err = as.service.RevokeAllForUser(ctx, model.RevokeAllForUserRequest{Token: token, UserId: req.UserId})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.RevokeAllForUserResponse{}, nil
+}
+
+func (as AuthService) EnrollMfa(ctx context.Context, req *authv1.EnrollMfaRequest) (*authv1.EnrollMfaResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	result, err := as.service.EnrollMfa(ctx, model.EnrollMfaRequest{
+		Token:    token,
+		Password: req.Password,
+		ClientIp: clientIp(ctx, as.trustForwardedFor),
 	})
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
+	}
+	return &authv1.EnrollMfaResponse{Secret: result.Secret, OtpauthUri: result.Uri}, nil
+}
+
+func (as AuthService) ConfirmMfaEnrollment(ctx context.Context, req *authv1.ConfirmMfaEnrollmentRequest) (*authv1.ConfirmMfaEnrollmentResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	result, err := as.service.ConfirmMfaEnrollment(ctx, model.ConfirmMfaEnrollmentRequest{Token: token, Code: req.Code})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.ConfirmMfaEnrollmentResponse{RecoveryCodes: result.RecoveryCodes}, nil
+}
+
+func (as AuthService) UnlockAccount(ctx context.Context, req *authv1.UnlockAccountRequest) (*authv1.UnlockAccountResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	err = as.service.UnlockAccount(ctx, model.UnlockAccountRequest{Token: token, Username: req.Username})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.UnlockAccountResponse{}, nil
+}
+
+func (as AuthService) ListSigningKeys(ctx context.Context, req *authv1.ListSigningKeysRequest) (*authv1.ListSigningKeysResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	result, err := as.service.ListSigningKeys(ctx, model.ListSigningKeysRequest{Token: token})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	keys := make([]*authv1.SigningKey, 0, len(result))
+	for _, key := range result {
+		keys = append(keys, toSigningKey(key))
+	}
+	return &authv1.ListSigningKeysResponse{Keys: keys}, nil
+}
+
+func (as AuthService) RotateSigningKey(ctx context.Context, req *authv1.RotateSigningKeyRequest) (*authv1.RotateSigningKeyResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	result, err := as.service.RotateSigningKey(ctx, model.RotateSigningKeyRequest{Token: token})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &authv1.RotateSigningKeyResponse{Key: toSigningKey(*result)}, nil
+}
+
+func toSigningKey(key model.SigningKeyInfo) *authv1.SigningKey {
+	state := authv1.SigningKeyState_SIGNING_KEY_STATE_UNSPECIFIED
+	switch key.State {
+	case model.SigningKeyStateActive:
+		state = authv1.SigningKeyState_SIGNING_KEY_STATE_ACTIVE
+	case model.SigningKeyStateVerifyOnly:
+		state = authv1.SigningKeyState_SIGNING_KEY_STATE_VERIFY_ONLY
+	case model.SigningKeyStateRetired:
+		state = authv1.SigningKeyState_SIGNING_KEY_STATE_RETIRED
+	}
+	return &authv1.SigningKey{
+		Kid:       key.Id,
+		Alg:       key.Algorithm,
+		State:     state,
+		CreatedAt: toTimestamp(key.CreatedAt),
+		RetireAt:  toTimestamp(key.RetireAt),
+	}
+}
+
+func toTokenInvalidReason(reason model.TokenInvalidReason) authv1.TokenInvalidReason {
+	switch reason {
+	case model.TokenInvalidReasonMalformed:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_MALFORMED
+	case model.TokenInvalidReasonSignature:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_SIGNATURE_INVALID
+	case model.TokenInvalidReasonExpired:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_EXPIRED
+	case model.TokenInvalidReasonNotYet:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_NOT_YET_VALID
+	case model.TokenInvalidReasonIssuer:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_ISSUER_INVALID
+	case model.TokenInvalidReasonAudience:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_AUDIENCE_INVALID
+	case model.TokenInvalidReasonRevoked:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_REVOKED
+	case model.TokenInvalidReasonInvalid:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_INVALID
+	default:
+		return authv1.TokenInvalidReason_TOKEN_INVALID_REASON_UNSPECIFIED
+	}
+}
+
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
 	}
-	return &authv1.LoginResponse{Token: result.Token}, nil
+	return timestamppb.New(t)
 }
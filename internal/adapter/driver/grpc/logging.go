@@ -0,0 +1,76 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// requestIdHeader carries the request id in both directions.
+	requestIdHeader = "x-request-id"
+	// healthMethodPrefix starts the methods of the grpc.health.v1 service.
+	healthMethodPrefix = "/grpc.health.v1.Health/"
+)
+
+// LoggingInterceptor gives every call a request id, taken from the
+// x-request-id metadata when the caller sent one, returns it in the
+// x-request-id header and logs the method, outcome and latency of the call.
+// It should run first so calls rejected by later interceptors are logged too.
+func LoggingInterceptor(logger *slog.Logger, trustForwardedFor bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		var sent string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIdHeader); len(values) > 0 {
+				sent = values[0]
+			}
+		}
+		requestId := logging.AcceptRequestId(sent)
+		ctx = logging.WithRequestId(ctx, requestId)
+		if err := grpc.SetHeader(ctx, metadata.Pairs(requestIdHeader, requestId)); err != nil {
+			logger.DebugContext(ctx, "Error setting request id header", "error", err)
+		}
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		attrs := []slog.Attr{
+			slog.String("method", info.FullMethod),
+			slog.String("status", code.String()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", clientIp(ctx, trustForwardedFor)),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+		}
+		level := callLevel(code)
+		if code == codes.OK && strings.HasPrefix(info.FullMethod, healthMethodPrefix) {
+			// Orchestrators probe every few seconds.
+			level = slog.LevelDebug
+		}
+		logger.LogAttrs(ctx, level, "gRPC call", attrs...)
+		return resp, err
+	}
+}
+
+// callLevel logs failures of the service as errors and the ones caused by
+// the caller as warnings.
+func callLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.Unimplemented:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
@@ -0,0 +1,73 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/logging"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeTransportStream records the headers set by interceptors.
+type fakeTransportStream struct {
+	header metadata.MD
+}
+
+func (s *fakeTransportStream) Method() string { return loginMethod }
+
+func (s *fakeTransportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *fakeTransportStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *fakeTransportStream) SetTrailer(md metadata.MD) error { return nil }
+
+func TestLoggingInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJson, "info")
+	assert.NoError(t, err)
+	interceptor := LoggingInterceptor(logger, false)
+
+	stream := &fakeTransportStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(requestIdHeader, "req-1", "authorization", "Bearer secret-token"))
+
+	var seen string
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: loginMethod}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		seen = logging.RequestId(ctx)
+		return nil, toStatus(domain.ErrInvalidAuth)
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "req-1", seen)
+	assert.Equal(t, []string{"req-1"}, stream.header.Get(requestIdHeader))
+
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, loginMethod, record["method"])
+	assert.Equal(t, "Unauthenticated", record["status"])
+	assert.Equal(t, "req-1", record[logging.RequestIdKey])
+	assert.Contains(t, record, "latency_ms")
+	assert.NotContains(t, buf.String(), "secret-token")
+}
+
+func TestLoggingInterceptor_HealthChecksAtDebug(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJson, "info")
+	assert.NoError(t, err)
+	interceptor := LoggingInterceptor(logger, false)
+
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), &fakeTransportStream{})
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: healthMethodPrefix + "Check"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
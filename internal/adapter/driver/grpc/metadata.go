@@ -0,0 +1,12 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/pkg/grpcauth"
+)
+
+// bearerToken extracts the token from the "authorization: Bearer <token>" metadata.
+func bearerToken(ctx context.Context) (string, error) {
+	return grpcauth.BearerToken(ctx)
+}
@@ -0,0 +1,20 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/driven"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsInterceptor records the latency and status code of every call.
+func MetricsInterceptor(metrics driven.Metrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		metrics.ObserveServerCall(info.FullMethod, status.Code(err).String(), time.Since(start))
+		return resp, err
+	}
+}
@@ -0,0 +1,29 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestMetricsInterceptor(t *testing.T) {
+	metrics := driven.NewMemoryMetrics()
+	interceptor := MetricsInterceptor(metrics)
+	info := &grpc.UnaryServerInfo{FullMethod: loginMethod}
+
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	assert.NoError(t, err)
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, toStatus(domain.ErrInvalidAuth)
+	})
+	assert.Error(t, err)
+
+	assert.Equal(t, 1, metrics.ServerCalls(loginMethod, "OK"))
+	assert.Equal(t, 1, metrics.ServerCalls(loginMethod, "Unauthenticated"))
+}
@@ -0,0 +1,36 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// clientIp returns the address of the caller. With trustForwardedFor the last
+// x-forwarded-for entry wins, which is the client as seen by the proxy in
+// front of this service; only enable it behind such a proxy.
+func clientIp(ctx context.Context, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("x-forwarded-for"); len(values) > 0 {
+				hops := strings.Split(values[len(values)-1], ",")
+				if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+					return ip
+				}
+			}
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
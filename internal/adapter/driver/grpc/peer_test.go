@@ -0,0 +1,23 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestClientIp(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	assert.Equal(t, "10.0.0.1", clientIp(ctx, false))
+
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", "1.1.1.1, 203.0.113.7"))
+	// A spoofed header is ignored unless the proxy is trusted.
+	assert.Equal(t, "10.0.0.1", clientIp(ctx, false))
+	assert.Equal(t, "203.0.113.7", clientIp(ctx, true))
+
+	assert.Equal(t, "", clientIp(context.Background(), true))
+}
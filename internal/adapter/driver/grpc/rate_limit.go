@@ -0,0 +1,152 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RateLimit allows Rate calls per second on average and bursts of up to Burst
+// calls. A zero Rate means no limit.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// RateLimitConfig selects the limit of a call. A limit configured for the
+// caller's identity wins over one for the full method name (for example
+// "/auth.v1.AuthService/Login"), which wins over Default. Every method and
+// identity pair gets a bucket of its own.
+type RateLimitConfig struct {
+	Default    RateLimit            `json:"default"`
+	Methods    map[string]RateLimit `json:"methods"`
+	Identities map[string]RateLimit `json:"identities"`
+}
+
+// LoadRateLimitConfig reads a RateLimitConfig from a JSON file.
+func LoadRateLimitConfig(path string) (RateLimitConfig, error) {
+	var config RateLimitConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid rate limit file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+func (c RateLimitConfig) limit(method, identity string) RateLimit {
+	if limit, ok := c.Identities[identity]; ok {
+		return limit
+	}
+	if limit, ok := c.Methods[method]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+// IdentityFunc names the caller of a request for rate limiting.
+type IdentityFunc func(ctx context.Context) string
+
+// ClientIpIdentity identifies callers by their address, see WithTrustForwardedFor.
+func ClientIpIdentity(trustForwardedFor bool) IdentityFunc {
+	return func(ctx context.Context) string {
+		return clientIp(ctx, trustForwardedFor)
+	}
+}
+
+type bucketKey struct {
+	method   string
+	identity string
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter throttles calls with one token bucket per method and identity.
+type RateLimiter struct {
+	config   RateLimitConfig
+	identify IdentityFunc
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(config RateLimitConfig, identify IdentityFunc) *RateLimiter {
+	return &RateLimiter{config: config, identify: identify, now: time.Now, buckets: map[bucketKey]*tokenBucket{}}
+}
+
+// UnaryInterceptor rejects calls over their limit with ResourceExhausted. The
+// status carries a RetryInfo detail and a "retry-after" header tells the
+// caller how many seconds to wait.
+func (rl *RateLimiter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		wait := rl.take(info.FullMethod, rl.identify(ctx))
+		if wait > 0 {
+			seconds := int64(math.Ceil(wait.Seconds()))
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.FormatInt(seconds, 10)))
+			return nil, toStatus(domain.WithRetryAfter(domain.ErrRateLimited, wait))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// take consumes a token for the call and returns how long to wait when none
+// is left.
+func (rl *RateLimiter) take(method, identity string) time.Duration {
+	limit := rl.config.limit(method, identity)
+	if limit.Rate <= 0 {
+		return 0
+	}
+	burst := float64(max(limit.Burst, 1))
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	key := bucketKey{method: method, identity: identity}
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, updated: now}
+		rl.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.Rate)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return 0
+}
+
+// sweep drops buckets that have been idle long enough to be full again, as
+// they behave like new ones. It runs at most once a minute.
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < time.Minute {
+		return
+	}
+	rl.lastSweep = now
+	for key, bucket := range rl.buckets {
+		limit := rl.config.limit(key.method, key.identity)
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.Rate >= float64(max(limit.Burst, 1)) {
+			delete(rl.buckets, key)
+		}
+	}
+}
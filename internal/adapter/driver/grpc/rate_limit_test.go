@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const loginMethod = "/auth.v1.AuthService/Login"
+
+func newTestRateLimiter(config RateLimitConfig, now *time.Time) *RateLimiter {
+	identify := func(ctx context.Context) string {
+		identity, _ := ctx.Value(identityKey{}).(string)
+		return identity
+	}
+	rl := NewRateLimiter(config, identify)
+	rl.now = func() time.Time { return *now }
+	return rl
+}
+
+type identityKey struct{}
+
+func callAs(rl *RateLimiter, identity, method string) error {
+	ctx := context.WithValue(context.Background(), identityKey{}, identity)
+	_, err := rl.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	return err
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newTestRateLimiter(RateLimitConfig{Default: RateLimit{Rate: 2, Burst: 2}}, &now)
+
+	assert.NoError(t, callAs(rl, "a", loginMethod))
+	assert.NoError(t, callAs(rl, "a", loginMethod))
+
+	err := callAs(rl, "a", loginMethod)
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = info
+		}
+	}
+	if assert.NotNil(t, retryInfo) {
+		assert.Equal(t, 500*time.Millisecond, retryInfo.RetryDelay.AsDuration())
+	}
+
+	// Other callers and other methods have buckets of their own.
+	assert.NoError(t, callAs(rl, "b", loginMethod))
+	assert.NoError(t, callAs(rl, "a", "/auth.v1.AuthService/GetJwks"))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.NoError(t, callAs(rl, "a", loginMethod))
+	assert.Error(t, callAs(rl, "a", loginMethod))
+}
+
+func TestRateLimiter_Overrides(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newTestRateLimiter(RateLimitConfig{
+		Methods:    map[string]RateLimit{loginMethod: {Rate: 1, Burst: 1}},
+		Identities: map[string]RateLimit{"trusted": {Rate: 100, Burst: 100}},
+	}, &now)
+
+	// Without a default limit other methods are not limited.
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, callAs(rl, "a", "/auth.v1.AuthService/GetJwks"))
+		assert.NoError(t, callAs(rl, "trusted", loginMethod))
+	}
+
+	assert.NoError(t, callAs(rl, "a", loginMethod))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(callAs(rl, "a", loginMethod)))
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newTestRateLimiter(RateLimitConfig{Default: RateLimit{Rate: 1, Burst: 5}}, &now)
+
+	assert.NoError(t, callAs(rl, "a", loginMethod))
+	assert.NoError(t, callAs(rl, "b", loginMethod))
+	assert.Len(t, rl.buckets, 2)
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, callAs(rl, "c", loginMethod))
+	assert.Len(t, rl.buckets, 1)
+}
@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	validator "github.com/go-playground/validator/v10"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain is the ErrorInfo domain of every error reported by this service.
+const errorDomain = "auth.finman"
+
+var kindCodes = map[domain.Kind]codes.Code{
+	domain.KindInternal:          codes.Internal,
+	domain.KindInvalidArgument:   codes.InvalidArgument,
+	domain.KindUnauthenticated:   codes.Unauthenticated,
+	domain.KindPermissionDenied:  codes.PermissionDenied,
+	domain.KindUnimplemented:     codes.Unimplemented,
+	domain.KindUnavailable:       codes.Unavailable,
+	domain.KindResourceExhausted: codes.ResourceExhausted,
+}
+
+// toStatus translates err into a gRPC status error. Catalogued domain errors
+// carry their code in an ErrorInfo reason, validation failures list their
+// field violations, and anything else is reported as Internal without
+// exposing its message.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		domainErr        *domain.Error
+		retryErr         *domain.RetryError
+		validationErrors validator.ValidationErrors
+		st               *status.Status
+		details          []protoadapt.MessageV1
+	)
+	switch {
+	case errors.As(err, &validationErrors):
+		st = status.New(codes.InvalidArgument, domain.ErrInvalidArgument.Message)
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(validationErrors))
+		for _, fe := range validationErrors {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldName(fe.Field()),
+				Description: "failed on the '" + fe.Tag() + "' rule",
+			})
+		}
+		details = append(details,
+			&errdetails.ErrorInfo{Reason: domain.ErrInvalidArgument.Code, Domain: errorDomain},
+			&errdetails.BadRequest{FieldViolations: violations},
+		)
+	case errors.As(err, &domainErr):
+		st = status.New(kindCodes[domainErr.Kind], domainErr.Message)
+		details = append(details, &errdetails.ErrorInfo{Reason: domainErr.Code, Domain: errorDomain})
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		slog.Error("Internal error", "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	if errors.As(err, &retryErr) {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryErr.RetryAfter)})
+	}
+
+	withDetails, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		slog.Error("Error attaching error details", "error", detailErr)
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// fieldName turns a Go field name into the lowerCamelCase used by clients.
+func fieldName(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
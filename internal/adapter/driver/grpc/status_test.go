@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	st := status.Convert(toStatus(domain.ErrInvalidAuth))
+	assert.Equal(t, codes.Unauthenticated, st.Code())
+	info := st.Details()[0].(*errdetails.ErrorInfo)
+	assert.Equal(t, "INVALID_AUTH", info.Reason)
+	assert.Equal(t, errorDomain, info.Domain)
+
+	st = status.Convert(toStatus(fmt.Errorf("%w: connection refused", domain.ErrUserServiceUnavailable)))
+	assert.Equal(t, codes.Unavailable, st.Code())
+
+	st = status.Convert(toStatus(domain.WithRetryAfter(domain.ErrRateLimited, 3*time.Second)))
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	details := st.Details()
+	assert.Len(t, details, 2)
+	assert.Equal(t, 3*time.Second, details[1].(*errdetails.RetryInfo).RetryDelay.AsDuration())
+
+	st = status.Convert(toStatus(errors.New("database password is hunter2")))
+	assert.Equal(t, codes.Internal, st.Code())
+	assert.NotContains(t, st.Message(), "hunter2")
+
+	assert.NoError(t, toStatus(nil))
+}
+
+func TestToStatus_FieldViolations(t *testing.T) {
+	err := model.CreateTokenRequest{Username: "user"}.Validate(context.Background())
+	st := status.Convert(toStatus(err))
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	var badRequest *errdetails.BadRequest
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			badRequest = br
+		}
+	}
+	assert.NotNil(t, badRequest)
+	assert.Len(t, badRequest.FieldViolations, 1)
+	assert.Equal(t, "password", badRequest.FieldViolations[0].Field)
+}
@@ -0,0 +1,229 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	validator "github.com/go-playground/validator/v10"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// loginPage is the minimal login form of the authorization endpoint. The
+// authorization request travels along in hidden fields. With an MFA token it
+// asks for the one-time code instead of the password.
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<h1>Sign in</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="/authorize">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientId}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectUri}}">
+<input type="hidden" name="scope" value="{{.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+{{if .MfaToken}}<input type="hidden" name="mfa_token" value="{{.MfaToken}}">
+<label>Authentication code <input name="otp" autocomplete="one-time-code" required autofocus></label>
+<button type="submit">Verify</button>
+{{else}}<label>Username <input name="username" autocomplete="username" required></label>
+<label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+<button type="submit">Sign in</button>
+{{end}}
+</form>
+</body>
+</html>
+`))
+
+// authorize shows the login form for a valid authorization request.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request) {
+	request := authorizeRequest(r.URL.Query())
+	if err := h.service.CheckAuthorizeRequest(r.Context(), request); err != nil {
+		writeAuthorizeError(w, r, request, err)
+		return
+	}
+	writeLoginPage(w, http.StatusOK, request, "", "")
+}
+
+// authorizeLogin checks the credentials of the login form and redirects back
+// to the client with an authorization code.
+func (h *Handler) authorizeLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "request body is not a valid form", http.StatusBadRequest)
+		return
+	}
+	request := authorizeRequest(r.PostForm)
+	if err := h.service.CheckAuthorizeRequest(r.Context(), request); err != nil {
+		writeAuthorizeError(w, r, request, err)
+		return
+	}
+
+	if mfaToken := r.PostForm.Get("mfa_token"); mfaToken != "" {
+		h.authorizeMfa(w, r, request, mfaToken)
+		return
+	}
+
+	result, err := h.service.Authorize(r.Context(), model.AuthorizeLoginRequest{
+		Authorization: request,
+		Username:      r.PostForm.Get("username"),
+		Password:      r.PostForm.Get("password"),
+		ClientIp:      h.clientIp(r),
+	})
+	var validationErrors validator.ValidationErrors
+	if errors.Is(err, domain.ErrInvalidAuth) || errors.As(err, &validationErrors) {
+		writeLoginPage(w, http.StatusUnauthorized, request, "", "Invalid username or password.")
+		return
+	}
+	if errors.Is(err, domain.ErrAccountLocked) || errors.Is(err, domain.ErrLoginThrottled) || errors.Is(err, domain.ErrRateLimited) {
+		writeRetryAfter(w, err)
+		writeLoginPage(w, http.StatusTooManyRequests, request, "", "Too many failed attempts. Please try again later.")
+		return
+	}
+	if err != nil {
+		writeAuthorizeError(w, r, request, err)
+		return
+	}
+	if result.MfaChallenge != "" {
+		writeLoginPage(w, http.StatusOK, request, result.MfaChallenge, "")
+		return
+	}
+
+	redirect(w, r, request, url.Values{"code": {result.Code}})
+}
+
+// authorizeMfa checks the one-time code of the MFA step of the login form.
+func (h *Handler) authorizeMfa(w http.ResponseWriter, r *http.Request, request model.AuthorizeRequest, mfaToken string) {
+	result, err := h.service.AuthorizeMfa(r.Context(), model.AuthorizeMfaRequest{
+		Authorization: request,
+		Challenge:     mfaToken,
+		Code:          r.PostForm.Get("otp"),
+		ClientIp:      h.clientIp(r),
+	})
+	var validationErrors validator.ValidationErrors
+	if errors.Is(err, domain.ErrMfaCodeInvalid) || errors.As(err, &validationErrors) {
+		writeLoginPage(w, http.StatusUnauthorized, request, mfaToken, "Invalid authentication code.")
+		return
+	}
+	if errors.Is(err, domain.ErrMfaChallengeInvalid) {
+		writeLoginPage(w, http.StatusUnauthorized, request, "", "Your sign-in has expired. Please sign in again.")
+		return
+	}
+	if errors.Is(err, domain.ErrAccountLocked) || errors.Is(err, domain.ErrLoginThrottled) || errors.Is(err, domain.ErrRateLimited) {
+		writeRetryAfter(w, err)
+		writeLoginPage(w, http.StatusTooManyRequests, request, mfaToken, "Too many failed attempts. Please try again later.")
+		return
+	}
+	if err != nil {
+		writeAuthorizeError(w, r, request, err)
+		return
+	}
+
+	redirect(w, r, request, url.Values{"code": {result.Code}})
+}
+
+func authorizeRequest(values url.Values) model.AuthorizeRequest {
+	return model.AuthorizeRequest{
+		ResponseType:        values.Get("response_type"),
+		ClientId:            values.Get("client_id"),
+		RedirectUri:         values.Get("redirect_uri"),
+		Scopes:              strings.Fields(values.Get("scope")),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+}
+
+// writeLoginPage renders the login form of request, whose redirect URI must
+// have been checked already.
+func writeLoginPage(w http.ResponseWriter, status int, request model.AuthorizeRequest, mfaToken, message string) {
+	// Keep the form out of caches and frames to prevent clickjacking. Browsers
+	// apply form-action to the redirect that answers the form too, so the
+	// origin of the redirect URI is allowed next to this server.
+	formAction := "'self'"
+	if origin := redirectOrigin(request.RedirectUri); origin != "" {
+		formAction += " " + origin
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; form-action "+formAction+"; frame-ancestors 'none'")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	err := loginPage.Execute(w, struct {
+		Request  model.AuthorizeRequest
+		Scope    string
+		MfaToken string
+		Error    string
+	}{request, strings.Join(request.Scopes, " "), mfaToken, message})
+	if err != nil {
+		slog.Error("Error writing login page", "error", err)
+	}
+}
+
+// redirectOrigin returns the CSP source matching the origin of uri, or "" when
+// it has none that can be expressed safely. Native apps redirecting to a
+// private scheme get a scheme source.
+func redirectOrigin(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return ""
+	}
+	source := u.Scheme + ":"
+	if u.Host != "" {
+		source = u.Scheme + "://" + u.Host
+	}
+	if strings.ContainsAny(source, " ;,'\"\t\r\n") {
+		return ""
+	}
+	return source
+}
+
+// writeAuthorizeError reports a failed authorization request. As required by
+// RFC 6749 section 4.1.2.1, the user is only sent back to the client once the
+// client and its redirect URI are known to be valid.
+func writeAuthorizeError(w http.ResponseWriter, r *http.Request, request model.AuthorizeRequest, err error) {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, domain.ErrUnsupported):
+		http.NotFound(w, r)
+	case errors.As(err, &validationErrors), errors.Is(err, domain.ErrInvalidClient), errors.Is(err, domain.ErrRedirectUriInvalid):
+		http.Error(w, "invalid client or redirect URI", http.StatusBadRequest)
+	case errors.Is(err, domain.ErrResponseTypeInvalid):
+		redirect(w, r, request, url.Values{"error": {"unsupported_response_type"}})
+	case errors.Is(err, domain.ErrCodeChallengeInvalid):
+		redirect(w, r, request, url.Values{"error": {"invalid_request"}, "error_description": {"code_challenge with method S256 is required"}})
+	case errors.Is(err, domain.ErrScopeInvalid):
+		redirect(w, r, request, url.Values{"error": {"invalid_scope"}})
+	default:
+		slog.ErrorContext(r.Context(), "Error authorizing", "error", err)
+		redirect(w, r, request, url.Values{"error": {"server_error"}})
+	}
+}
+
+// redirect sends the user back to the redirect URI of the request with params
+// and the state of the request added to its query.
+func redirect(w http.ResponseWriter, r *http.Request, request model.AuthorizeRequest, params url.Values) {
+	target, err := url.Parse(request.RedirectUri)
+	if err != nil {
+		http.Error(w, "invalid redirect URI", http.StatusBadRequest)
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
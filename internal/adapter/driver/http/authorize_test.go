@@ -0,0 +1,90 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	driver "github.com/nullexp/finman-auth-service/internal/adapter/driver/service"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectOrigin(t *testing.T) {
+	assert.Equal(t, "https://app.finman.test:8443", redirectOrigin("https://app.finman.test:8443/callback?x=1"))
+	assert.Equal(t, "com.finman.app:", redirectOrigin("com.finman.app:/callback"))
+	assert.Equal(t, "", redirectOrigin("/relative"))
+	assert.Equal(t, "", redirectOrigin("https://app.finman.test;script-src/"))
+}
+
+func TestHandler_AuthorizationCodeFlow(t *testing.T) {
+	registry, err := driven.NewMemoryClientRegistry(model.Client{Id: "spa", Public: true, Scopes: []string{model.ScopeOpenId, model.ScopeProfile}, RedirectUris: []string{"https://app.finman.test/callback"}})
+	assert.NoError(t, err)
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123"}, nil)
+	handler := NewHandler(driver.NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		driver.WithClientRegistry(registry),
+		driver.WithAuthorizationCodes(driven.NewMemoryAuthorizationCodeStore(), time.Minute),
+	))
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"spa"},
+		"redirect_uri":          {"https://app.finman.test/callback"},
+		"state":                 {"xyz"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `name="password"`)
+	assert.Equal(t, "DENY", recorder.Header().Get("X-Frame-Options"))
+	// The redirect answering the form goes to the client, so its origin is allowed.
+	assert.Equal(t, "default-src 'none'; form-action 'self' https://app.finman.test; frame-ancestors 'none'", recorder.Header().Get("Content-Security-Policy"))
+
+	params.Set("username", "user")
+	params.Set("password", "pass")
+	request := httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(params.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusFound, recorder.Code)
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "app.finman.test", location.Host)
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+
+	recorder, body := postToken(handler, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {"https://app.finman.test/callback"},
+		"client_id":     {"spa"},
+		"code_verifier": {verifier},
+	}, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotEmpty(t, body["access_token"])
+
+	// An unregistered redirect URI is never redirected to.
+	params.Set("redirect_uri", "https://evil.test/callback")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	params.Set("redirect_uri", "https://app.finman.test/callback")
+	params.Del("code_challenge")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil))
+	assert.Equal(t, http.StatusFound, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Location"), "error=invalid_request")
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/logging"
+	"github.com/nullexp/finman-auth-service/internal/port/driver"
+)
+
+// requestIdHeader carries the request id in both directions.
+const requestIdHeader = "X-Request-Id"
+
+// Handler serves the HTTP endpoints of the auth service for clients that
+// cannot speak gRPC.
+type Handler struct {
+	service           driver.AuthService
+	mux               *http.ServeMux
+	logger            *slog.Logger
+	readiness         Readiness
+	trustForwardedFor bool
+}
+
+// Option configures optional behaviour of a Handler.
+type Option func(*Handler)
+
+// WithTrustForwardedFor takes the client address from the X-Forwarded-For
+// header instead of the connection. Only use it behind a trusted proxy.
+func WithTrustForwardedFor() Option {
+	return func(h *Handler) {
+		h.trustForwardedFor = true
+	}
+}
+
+// WithLogger logs requests to logger instead of the default logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithReadiness serves the readiness of the service at /readyz.
+func WithReadiness(readiness Readiness) Option {
+	return func(h *Handler) {
+		h.readiness = readiness
+	}
+}
+
+func NewHandler(as driver.AuthService, opts ...Option) *Handler {
+	h := &Handler{service: as, mux: http.NewServeMux(), logger: slog.Default()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("GET /.well-known/jwks.json", h.jwks)
+	h.mux.HandleFunc("GET /.well-known/openid-configuration", h.openIdConfiguration)
+	h.mux.HandleFunc("GET /authorize", h.authorize)
+	h.mux.HandleFunc("POST /authorize", h.authorizeLogin)
+	h.mux.HandleFunc("POST /oauth/token", h.token)
+	h.mux.HandleFunc("GET /userinfo", h.userInfo)
+	h.mux.HandleFunc("POST /userinfo", h.userInfo)
+	h.mux.HandleFunc("GET /healthz", h.healthz)
+	if h.readiness != nil {
+		h.mux.HandleFunc("GET /readyz", h.readyz)
+	}
+	return h
+}
+
+// ServeHTTP gives every request a request id, taken from the X-Request-Id
+// header when the client sent one, and logs the method, path, status and
+// latency of the request. The query is left out as it may carry codes.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	requestId := logging.AcceptRequestId(r.Header.Get(requestIdHeader))
+	r = r.WithContext(logging.WithRequestId(r.Context(), requestId))
+	w.Header().Set(requestIdHeader, requestId)
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	h.mux.ServeHTTP(recorder, r)
+
+	level := slog.LevelInfo
+	switch {
+	case isProbe(r.URL.Path) && recorder.status < http.StatusBadRequest:
+		// Orchestrators probe every few seconds.
+		level = slog.LevelDebug
+	case recorder.status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case recorder.status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+	h.logger.LogAttrs(r.Context(), level, "HTTP request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", recorder.status),
+		slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.String("client_ip", h.clientIp(r)),
+	)
+}
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (h *Handler) jwks(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.GetJwks(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting jwks", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	// Let verifiers cache the key set for a few minutes.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, result)
+}
+
+// clientIp returns the address of the client; see WithTrustForwardedFor.
+func (h *Handler) clientIp(r *http.Request) string {
+	if h.trustForwardedFor {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			hops := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Error writing response", "error", err)
+	}
+}
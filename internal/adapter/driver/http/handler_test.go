@@ -0,0 +1,34 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	driver "github.com/nullexp/finman-auth-service/internal/adapter/driver/service"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_Jwks(t *testing.T) {
+	tokenService := driven.NewTokenService("test-secret", time.Hour)
+	handler := NewHandler(driver.NewAuthService(driven.NewMockUserService(), tokenService))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var set model.JsonWebKeySet
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&set))
+	// HMAC secrets are never published.
+	assert.Empty(t, set.Keys)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/.well-known/jwks.json", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
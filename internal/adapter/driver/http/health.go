@@ -0,0 +1,34 @@
+package http
+
+import "net/http"
+
+// Readiness reports whether the service can take traffic and the outcome of
+// each of its checks.
+type Readiness interface {
+	Ready() (bool, map[string]string)
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func isProbe(path string) bool {
+	return path == "/healthz" || path == "/readyz"
+}
+
+// healthz answers as long as the process serves requests.
+func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// readyz answers 503 while a dependency needed to issue tokens is unavailable.
+func (h *Handler) readyz(w http.ResponseWriter, r *http.Request) {
+	ready, checks := h.readiness.Ready()
+	w.Header().Set("Cache-Control", "no-store")
+	if !ready {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "not ready", Checks: checks})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ready", Checks: checks})
+}
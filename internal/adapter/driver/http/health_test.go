@@ -0,0 +1,57 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	driver "github.com/nullexp/finman-auth-service/internal/adapter/driver/service"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeReadiness struct {
+	ready  bool
+	checks map[string]string
+}
+
+func (r *fakeReadiness) Ready() (bool, map[string]string) {
+	return r.ready, r.checks
+}
+
+func TestHandler_Health(t *testing.T) {
+	as := driver.NewAuthService(driven.NewMockUserService(), driven.NewTokenService("test-secret", time.Hour))
+	readiness := &fakeReadiness{checks: map[string]string{"user_service": "connection is TRANSIENT_FAILURE"}}
+	handler := NewHandler(as, WithReadiness(readiness))
+
+	get := func(path string) (int, healthResponse) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		var body healthResponse
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&body))
+		return recorder.Code, body
+	}
+
+	// The process is alive even while it is not ready.
+	code, body := get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body.Status)
+
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready", body.Status)
+	assert.Equal(t, readiness.checks, body.Checks)
+
+	readiness.ready = true
+	readiness.checks = map[string]string{"user_service": "ok"}
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body.Status)
+
+	// Without a readiness there is nothing to report.
+	recorder := httptest.NewRecorder()
+	NewHandler(as).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	driver "github.com/nullexp/finman-auth-service/internal/adapter/driver/service"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTotp accepts "123456" for a step that grows with every check, so the
+// same code can be entered more than once.
+type fakeTotp struct {
+	step int64
+}
+
+func (f *fakeTotp) GenerateSecret() (string, error)       { return "SECRET", nil }
+func (f *fakeTotp) Uri(secret, accountName string) string { return "otpauth://totp/" + accountName }
+
+func (f *fakeTotp) Verify(secret, code string) (int64, bool) {
+	f.step++
+	return f.step, code == "123456"
+}
+
+func newMfaHandler(t *testing.T) *Handler {
+	registry, err := driven.NewMemoryClientRegistry(model.Client{Id: "spa", Public: true, Scopes: []string{model.ScopeOpenId, model.ScopeProfile}, RedirectUris: []string{"https://app.finman.test/callback"}})
+	assert.NoError(t, err)
+	mfaStore := driven.NewMemoryMfaStore()
+	assert.NoError(t, mfaStore.Save(context.Background(), model.MfaEnrollment{UserId: "123", Secret: "SECRET", Confirmed: true}))
+
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123"}, nil)
+	return NewHandler(driver.NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		driver.WithClientRegistry(registry),
+		driver.WithAuthorizationCodes(driven.NewMemoryAuthorizationCodeStore(), time.Minute),
+		driver.WithMfa(&fakeTotp{}, mfaStore, driven.NewMemoryMfaChallengeStore(), time.Minute),
+	))
+}
+
+func TestHandler_TokenMfaGrant(t *testing.T) {
+	handler := newMfaHandler(t)
+
+	recorder, body := postToken(handler, url.Values{"grant_type": {"password"}, "username": {"user"}, "password": {"pass"}}, nil)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, "mfa_required", body["error"])
+	assert.Nil(t, body["access_token"])
+	mfaToken := body["mfa_token"].(string)
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {mfaOtpGrantType}, "mfa_token": {mfaToken}, "otp": {"000000"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {mfaOtpGrantType}, "mfa_token": {mfaToken}, "otp": {"123456"}}, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotEmpty(t, body["access_token"])
+}
+
+func TestHandler_AuthorizeMfa(t *testing.T) {
+	handler := newMfaHandler(t)
+	sum := sha256.Sum256([]byte("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"spa"},
+		"redirect_uri":          {"https://app.finman.test/callback"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	postForm := func(form url.Values) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	params.Set("username", "user")
+	params.Set("password", "pass")
+	recorder := postForm(params)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `name="otp"`)
+	assert.NotContains(t, recorder.Body.String(), `name="password"`)
+	match := regexp.MustCompile(`name="mfa_token" value="([^"]+)"`).FindStringSubmatch(recorder.Body.String())
+	if !assert.Len(t, match, 2) {
+		return
+	}
+
+	params.Del("username")
+	params.Del("password")
+	params.Set("mfa_token", match[1])
+	params.Set("otp", "000000")
+	recorder = postForm(params)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Invalid authentication code.")
+
+	params.Set("otp", "123456")
+	recorder = postForm(params)
+	assert.Equal(t, http.StatusFound, recorder.Code)
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, location.Query().Get("code"))
+}
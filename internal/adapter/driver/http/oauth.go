@@ -0,0 +1,224 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	validator "github.com/go-playground/validator/v10"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// tokenResponse is the successful access token response of RFC 6749 section 5.1.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// errorResponse is the error response of RFC 6749 section 5.2.
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	// MfaToken comes with the mfa_required error and is redeemed with the
+	// mfaOtpGrantType grant.
+	MfaToken string `json:"mfa_token,omitempty"`
+}
+
+// mfaOtpGrantType completes a password grant that answered mfa_required with
+// the mfa_token and a TOTP or recovery code in otp.
+const mfaOtpGrantType = "urn:finman:params:oauth:grant-type:mfa-otp"
+
+// token implements the RFC 6749 token endpoint for the password,
+// client_credentials, authorization_code and refresh_token grants, plus
+// mfaOtpGrantType for users with MFA.
+func (h *Handler) token(w http.ResponseWriter, r *http.Request) {
+	// Token responses carry credentials and must never be cached.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "request body is not a valid form")
+		return
+	}
+	form := r.PostForm
+
+	var (
+		result *model.CreateTokenResponse
+		err    error
+	)
+	switch grantType := form.Get("grant_type"); grantType {
+	case "password":
+		// Client authentication is optional here, but ID tokens only go to
+		// clients that authenticated.
+		clientId, clientSecret, ok := clientCredentials(r)
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "client credentials must be sent exactly once")
+			return
+		}
+		result, err = h.service.CreateToken(r.Context(), model.CreateTokenRequest{
+			Username:     form.Get("username"),
+			Password:     form.Get("password"),
+			Scopes:       strings.Fields(form.Get("scope")),
+			ClientId:     clientId,
+			ClientSecret: clientSecret,
+			Nonce:        form.Get("nonce"),
+			ClientIp:     h.clientIp(r),
+		})
+	case "client_credentials":
+		clientId, clientSecret, ok := clientCredentials(r)
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "client credentials must be sent exactly once")
+			return
+		}
+		if clientId == "" || clientSecret == "" {
+			writeInvalidClient(w, "client authentication is required")
+			return
+		}
+		result, err = h.service.CreateClientToken(r.Context(), model.ClientCredentialsRequest{
+			ClientId:     clientId,
+			ClientSecret: clientSecret,
+			Scopes:       strings.Fields(form.Get("scope")),
+		})
+	case "authorization_code":
+		clientId, clientSecret, ok := clientCredentials(r)
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "client credentials must be sent exactly once")
+			return
+		}
+		if clientId == "" {
+			// Public clients send no secret, but every client names itself.
+			writeInvalidClient(w, "client authentication is required")
+			return
+		}
+		result, err = h.service.ExchangeAuthorizationCode(r.Context(), model.AuthorizationCodeRequest{
+			Code:         form.Get("code"),
+			RedirectUri:  form.Get("redirect_uri"),
+			ClientId:     clientId,
+			ClientSecret: clientSecret,
+			CodeVerifier: form.Get("code_verifier"),
+		})
+	case "refresh_token":
+		// Refresh tokens issued to a client can only be used by that client.
+		clientId, clientSecret, ok := clientCredentials(r)
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "client credentials must be sent exactly once")
+			return
+		}
+		result, err = h.service.RefreshToken(r.Context(), model.RefreshTokenRequest{
+			RefreshToken: form.Get("refresh_token"),
+			ClientId:     clientId,
+			ClientSecret: clientSecret,
+		})
+	case mfaOtpGrantType:
+		result, err = h.service.CompleteMfa(r.Context(), model.CompleteMfaRequest{
+			Challenge: form.Get("mfa_token"),
+			Code:      form.Get("otp"),
+			ClientIp:  h.clientIp(r),
+		})
+	case "":
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+	if err != nil {
+		writeTokenError(w, r, err)
+		return
+	}
+	if result.MfaChallenge != "" {
+		writeJSON(w, http.StatusForbidden, errorResponse{
+			Error:            "mfa_required",
+			ErrorDescription: "a one-time code is required to complete the login",
+			MfaToken:         result.MfaChallenge,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  result.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(result.ExpiresIn.Seconds()),
+		RefreshToken: result.RefreshToken,
+		IdToken:      result.IdToken,
+		Scope:        strings.Join(result.Scopes, " "),
+	})
+}
+
+// clientCredentials returns the client id and secret sent either with HTTP
+// Basic authentication or in the form body. Using both is not allowed.
+func clientCredentials(r *http.Request) (string, string, bool) {
+	id, secret, basic := r.BasicAuth()
+	formId := r.PostForm.Get("client_id")
+	if basic {
+		if formId != "" {
+			return "", "", false
+		}
+		// Basic credentials are form encoded before being joined (section 2.3.1).
+		id, idErr := url.QueryUnescape(id)
+		secret, secretErr := url.QueryUnescape(secret)
+		return id, secret, idErr == nil && secretErr == nil
+	}
+	return formId, r.PostForm.Get("client_secret"), true
+}
+
+func writeTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrors):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "")
+	case errors.Is(err, domain.ErrInvalidClient):
+		writeInvalidClient(w, "")
+	case errors.Is(err, domain.ErrInvalidAuth),
+		errors.Is(err, domain.ErrRefreshTokenInvalid),
+		errors.Is(err, domain.ErrRefreshTokenExpired),
+		errors.Is(err, domain.ErrRefreshTokenReused),
+		errors.Is(err, domain.ErrAuthCodeInvalid),
+		errors.Is(err, domain.ErrMfaCodeInvalid),
+		errors.Is(err, domain.ErrMfaChallengeInvalid):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "")
+	case errors.Is(err, domain.ErrScopeInvalid):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "")
+	case errors.Is(err, domain.ErrUnsupported):
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	case errors.Is(err, domain.ErrAccountLocked),
+		errors.Is(err, domain.ErrLoginThrottled),
+		errors.Is(err, domain.ErrRateLimited):
+		writeRetryAfter(w, err)
+		writeOAuthError(w, http.StatusTooManyRequests, "slow_down", "")
+	case errors.Is(err, domain.ErrUserServiceUnavailable):
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+	default:
+		slog.ErrorContext(r.Context(), "Error issuing token", "error", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+	}
+}
+
+// writeRetryAfter sets the Retry-After header when err says when to retry.
+func writeRetryAfter(w http.ResponseWriter, err error) {
+	var retryErr *domain.RetryError
+	if errors.As(err, &retryErr) {
+		seconds := int64(math.Ceil(retryErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+}
+
+// writeInvalidClient answers a failed client authentication with 401 and the
+// challenge of the Basic scheme (RFC 6749 section 5.2).
+func writeInvalidClient(w http.ResponseWriter, description string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	writeOAuthError(w, http.StatusUnauthorized, "invalid_client", description)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, errorResponse{Error: code, ErrorDescription: description})
+}
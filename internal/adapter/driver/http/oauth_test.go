@@ -0,0 +1,127 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	driver "github.com/nullexp/finman-auth-service/internal/adapter/driver/service"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newOAuthHandler(t *testing.T) *Handler {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registry, err := driven.NewMemoryClientRegistry(model.Client{Id: "reports", SecretHash: string(hash), Scopes: []string{"reports:read"}})
+	assert.NoError(t, err)
+
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123"}, nil)
+	authService := driver.NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		driver.WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		driver.WithClientRegistry(registry),
+	)
+	return NewHandler(authService)
+}
+
+func postToken(handler *Handler, form url.Values, modify func(*http.Request)) (*httptest.ResponseRecorder, map[string]interface{}) {
+	request := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if modify != nil {
+		modify(request)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	body := map[string]interface{}{}
+	json.NewDecoder(recorder.Body).Decode(&body)
+	return recorder, body
+}
+
+func TestHandler_TokenPasswordGrant(t *testing.T) {
+	handler := newOAuthHandler(t)
+
+	recorder, body := postToken(handler, url.Values{"grant_type": {"password"}, "username": {"user"}, "password": {"pass"}}, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+	assert.Equal(t, "Bearer", body["token_type"])
+	assert.Equal(t, float64(3600), body["expires_in"])
+	assert.NotEmpty(t, body["access_token"])
+	assert.NotEmpty(t, body["refresh_token"])
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)}}, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotEmpty(t, body["access_token"])
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {"password"}, "username": {"user"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "invalid_request", body["error"])
+}
+
+func TestHandler_TokenRefreshGrantAuthenticatesClient(t *testing.T) {
+	handler := newOAuthHandler(t)
+	basicAuth := func(secret string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth("reports", secret) }
+	}
+
+	recorder, body := postToken(handler, url.Values{"grant_type": {"password"}, "username": {"user"}, "password": {"pass"}}, basicAuth("s3cret"))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	refreshToken := body["refresh_token"].(string)
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}, nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}, basicAuth("wrong"))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, "invalid_client", body["error"])
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}, basicAuth("s3cret"))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotEmpty(t, body["access_token"])
+}
+
+func TestHandler_TokenClientCredentialsGrant(t *testing.T) {
+	handler := newOAuthHandler(t)
+
+	recorder, body := postToken(handler, url.Values{"grant_type": {"client_credentials"}}, func(r *http.Request) {
+		r.SetBasicAuth("reports", "s3cret")
+	})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "reports:read", body["scope"])
+	assert.Nil(t, body["refresh_token"])
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {"client_credentials"}, "client_id": {"reports"}, "client_secret": {"s3cret"}, "scope": {"reports:write"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "invalid_scope", body["error"])
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {"client_credentials"}}, func(r *http.Request) {
+		r.SetBasicAuth("reports", "wrong")
+	})
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, "invalid_client", body["error"])
+	assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+
+	// Missing credentials are a failed client authentication too.
+	for _, form := range []url.Values{
+		{"grant_type": {"client_credentials"}},
+		{"grant_type": {"client_credentials"}, "client_id": {"reports"}},
+		{"grant_type": {"authorization_code"}, "code": {"code"}},
+	} {
+		recorder, body = postToken(handler, form, nil)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Equal(t, "invalid_client", body["error"])
+		assert.Equal(t, `Basic realm="oauth"`, recorder.Header().Get("WWW-Authenticate"))
+	}
+
+	recorder, body = postToken(handler, url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:device_code"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "unsupported_grant_type", body["error"])
+}
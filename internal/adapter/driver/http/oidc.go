@@ -0,0 +1,83 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+func (h *Handler) openIdConfiguration(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.GetOpenIdConfiguration(r.Context())
+	if errors.Is(err, domain.ErrUnsupported) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting openid configuration", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, result)
+}
+
+// userInfo implements the OpenID Connect userinfo endpoint. Errors are
+// reported with the WWW-Authenticate header of RFC 6750.
+func (h *Handler) userInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	result, err := h.service.GetUserInfo(r.Context(), model.UserInfoRequest{Token: token})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrPermissionDenied):
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			w.WriteHeader(http.StatusForbidden)
+		case errors.Is(err, domain.ErrInvalidAuth), isTokenError(err):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			slog.ErrorContext(r.Context(), "Error getting user info", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func isTokenError(err error) bool {
+	for _, tokenErr := range []error{
+		domain.ErrTokenMalformed,
+		domain.ErrTokenSignatureInvalid,
+		domain.ErrTokenExpired,
+		domain.ErrTokenNotYetValid,
+		domain.ErrTokenIssuerInvalid,
+		domain.ErrTokenAudienceInvalid,
+		domain.ErrTokenInvalid,
+		domain.ErrTokenRevoked,
+	} {
+		if errors.Is(err, tokenErr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	driver "github.com/nullexp/finman-auth-service/internal/adapter/driver/service"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_OpenIdConfiguration(t *testing.T) {
+	tokenService := driven.NewTokenService("test-secret", time.Hour, driven.WithIssuer("https://auth.finman.test"))
+	handler := NewHandler(driver.NewAuthService(driven.NewMockUserService(), tokenService))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var config model.OpenIdConfiguration
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&config))
+	assert.Equal(t, "https://auth.finman.test", config.Issuer)
+	assert.Equal(t, "https://auth.finman.test/userinfo", config.UserInfoEndpoint)
+}
+
+func TestHandler_UserInfo(t *testing.T) {
+	handler := newOAuthHandler(t)
+
+	_, body := postToken(handler, url.Values{"grant_type": {"password"}, "username": {"user"}, "password": {"pass"}, "scope": {"openid"}}, nil)
+	assert.NotEmpty(t, body["id_token"])
+
+	request := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	request.Header.Set("Authorization", "Bearer "+body["access_token"].(string))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"sub": "123"}`, recorder.Body.String())
+
+	request = httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	request.Header.Set("Authorization", "Bearer not-a-token")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), "invalid_token")
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/userinfo", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -2,19 +2,133 @@ package driver
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/nullexp/finman-auth-service/internal/domain"
 	"github.com/nullexp/finman-auth-service/internal/port/driven"
 	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type AuthService struct {
-	userService  driven.UserService
-	tokenService driven.TokenService
+	userService         driven.UserService
+	tokenService        driven.TokenService
+	refreshStore        driven.RefreshTokenStore
+	refreshExpireAfter  time.Duration
+	revocations         driven.RevocationStore
+	keyManager          driven.KeyManager
+	clients             driven.ClientRegistry
+	authCodes           driven.AuthorizationCodeStore
+	authCodeExpireAfter time.Duration
+	minLoginDuration    time.Duration
+	throttler           driven.LoginThrottler
+	totp                driven.Totp
+	mfaStore            driven.MfaStore
+	mfaChallenges       driven.MfaChallengeStore
+	mfaExpireAfter      time.Duration
+	roles               driven.RolePolicy
+	policy              driven.PolicyEngine
+	metrics             driven.Metrics
+	tracer              trace.Tracer
 }
 
-func NewAuthService(userService driven.UserService, tokenService driven.TokenService) *AuthService {
-	return &AuthService{userService: userService, tokenService: tokenService}
+// Option configures optional behaviour of an AuthService.
+type Option func(*AuthService)
+
+// WithRefreshTokens makes Login issue rotating refresh tokens kept in store.
+func WithRefreshTokens(store driven.RefreshTokenStore, expireAfter time.Duration) Option {
+	return func(as *AuthService) {
+		as.refreshStore = store
+		as.refreshExpireAfter = expireAfter
+	}
+}
+
+// WithRevocationStore enables Logout, RevokeToken and RevokeAllForUser.
+func WithRevocationStore(store driven.RevocationStore) Option {
+	return func(as *AuthService) {
+		as.revocations = store
+	}
+}
+
+// WithKeyManager enables ListSigningKeys and RotateSigningKey.
+func WithKeyManager(manager driven.KeyManager) Option {
+	return func(as *AuthService) {
+		as.keyManager = manager
+	}
+}
+
+// WithClientRegistry enables CreateClientToken for the clients in registry.
+func WithClientRegistry(registry driven.ClientRegistry) Option {
+	return func(as *AuthService) {
+		as.clients = registry
+	}
+}
+
+// WithAuthorizationCodes enables the authorization code flow. Codes are kept
+// in store and expire after expireAfter; it requires a client registry.
+func WithAuthorizationCodes(store driven.AuthorizationCodeStore, expireAfter time.Duration) Option {
+	return func(as *AuthService) {
+		as.authCodes = store
+		as.authCodeExpireAfter = expireAfter
+	}
+}
+
+// WithMinLoginDuration makes every credential check take at least d, so
+// response times do not reveal whether a username exists.
+func WithMinLoginDuration(d time.Duration) Option {
+	return func(as *AuthService) {
+		as.minLoginDuration = d
+	}
+}
+
+// WithLoginThrottler checks every login against throttler before the user
+// service sees it, and enables UnlockAccount.
+func WithLoginThrottler(throttler driven.LoginThrottler) Option {
+	return func(as *AuthService) {
+		as.throttler = throttler
+	}
+}
+
+// WithMfa enables TOTP enrollment and makes logins of enrolled users return
+// a challenge, kept in challenges for expireAfter, instead of tokens.
+func WithMfa(totp driven.Totp, store driven.MfaStore, challenges driven.MfaChallengeStore, expireAfter time.Duration) Option {
+	return func(as *AuthService) {
+		as.totp = totp
+		as.mfaStore = store
+		as.mfaChallenges = challenges
+		as.mfaExpireAfter = expireAfter
+	}
+}
+
+// WithRolePolicy grants users the permissions of their role as scopes.
+func WithRolePolicy(policy driven.RolePolicy) Option {
+	return func(as *AuthService) {
+		as.roles = policy
+	}
+}
+
+// WithPolicyEngine enables CheckPermission, decided by engine.
+func WithPolicyEngine(engine driven.PolicyEngine) Option {
+	return func(as *AuthService) {
+		as.policy = engine
+	}
+}
+
+// WithMetrics records logins, issued tokens and token validations in metrics.
+func WithMetrics(metrics driven.Metrics) Option {
+	return func(as *AuthService) {
+		as.metrics = metrics
+	}
+}
+
+func NewAuthService(userService driven.UserService, tokenService driven.TokenService, opts ...Option) *AuthService {
+	as := &AuthService{userService: userService, tokenService: tokenService, metrics: nopMetrics{}, tracer: defaultTracer()}
+	for _, opt := range opts {
+		opt(as)
+	}
+	return as
 }
 
 func (as AuthService) CreateToken(ctx context.Context, dto model.CreateTokenRequest) (*model.CreateTokenResponse, error) {
@@ -22,21 +136,159 @@ func (as AuthService) CreateToken(ctx context.Context, dto model.CreateTokenRequ
 		return nil, err
 	}
 
-	user, err := as.userService.GetUser(ctx, dto.Username, dto.Password)
+	client, err := as.loginClient(ctx, dto.ClientId, dto.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClientScopes(client, dto.Scopes); err != nil {
+		return nil, err
+	}
+
+	user, err := as.checkCredentials(ctx, model.LoginAttempt{Username: dto.Username, ClientIp: dto.ClientIp}, dto.Password)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := as.userSubject(ctx, user, dto.Scopes, client)
+	if err != nil {
+		return nil, err
+	}
+	var clientId string
+	if client != nil {
+		clientId = client.Id
+	}
+	authTime := time.Now()
+
+	challenge, err := as.startMfa(ctx, model.MfaChallenge{Subject: subject, Username: dto.Username, ClientId: clientId, Nonce: dto.Nonce, AuthTime: authTime})
 	if err != nil {
 		return nil, err
 	}
+	if challenge != "" {
+		return &model.CreateTokenResponse{MfaChallenge: challenge}, nil
+	}
+
+	return as.issueLoginTokens(ctx, subject, clientId, dto.Nonce, authTime)
+}
 
-	if user == nil {
-		return nil, domain.ErrInvalidAuth
+// loginClient authenticates the client a login names, as ID tokens are issued
+// to it. Logins without a client are allowed; their ID tokens are addressed
+// to the audience of the service.
+func (as AuthService) loginClient(ctx context.Context, clientId, secret string) (*model.Client, error) {
+	if clientId == "" {
+		return nil, nil
+	}
+	if as.clients == nil {
+		return nil, domain.ErrInvalidClient
+	}
+	client, err := as.clients.Authenticate(ctx, clientId, secret)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domain.ErrInvalidClient
 	}
+	return client, nil
+}
 
-	token, err := as.tokenService.CreateToken(model.Subject{UserId: user.Id, IsAdmin: user.IsAdmin})
+// issueLoginTokens issues the tokens of an authenticated user, including an
+// ID token for clientId when the openid scope was granted.
+func (as AuthService) issueLoginTokens(ctx context.Context, subject model.Subject, clientId, nonce string, authTime time.Time) (*model.CreateTokenResponse, error) {
+	response, err := as.issueTokens(ctx, subject, clientId, "")
 	if err != nil {
 		return nil, err
 	}
 
-	return &model.CreateTokenResponse{
-		Token: token,
+	if model.HasScope(subject.Scopes, model.ScopeOpenId) {
+		response.IdToken, err = as.createIdToken(ctx, subject.UserId, clientId, nonce, authTime, subject.Scopes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// issueTokens creates an access token for subject and, when refresh tokens are
+// enabled, a refresh token in familyId for clientId. An empty familyId starts
+// a new family.
+func (as AuthService) issueTokens(ctx context.Context, subject model.Subject, clientId, familyId string) (*model.CreateTokenResponse, error) {
+	token, err := as.signAccessToken(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &model.CreateTokenResponse{Token: token, ExpiresIn: as.tokenService.ExpireAfter(), Scopes: subject.Scopes}
+	if as.refreshStore == nil {
+		return response, nil
+	}
+
+	if familyId == "" {
+		familyId = uuid.NewString()
+	}
+	response.RefreshToken, err = as.createRefreshToken(ctx, subject, clientId, familyId)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (as AuthService) ValidateToken(ctx context.Context, dto model.ValidateTokenRequest) (*model.ValidateTokenResponse, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	claims, subject, err := as.authenticate(ctx, dto.Token)
+	if err != nil {
+		reason, ok := tokenInvalidReason(err)
+		if !ok {
+			return nil, err
+		}
+		return &model.ValidateTokenResponse{Reason: reason}, nil
+	}
+
+	return &model.ValidateTokenResponse{
+		Valid:     true,
+		Subject:   subject,
+		Identity:  claims.Identity,
+		IssuedAt:  unixTime(claims.IssuedAt),
+		ExpiresAt: unixTime(claims.ExpiresAt),
 	}, nil
 }
+
+// GetJwks returns the public keys other services need to verify tokens.
+func (as AuthService) GetJwks(ctx context.Context) (*model.JsonWebKeySet, error) {
+	jwks := as.tokenService.Jwks()
+	return &jwks, nil
+}
+
+// tokenInvalidReason maps a token error to the reason reported to callers.
+// It returns false when err is not a token validation failure.
+func tokenInvalidReason(err error) (model.TokenInvalidReason, bool) {
+	switch {
+	case errors.Is(err, domain.ErrTokenMalformed):
+		return model.TokenInvalidReasonMalformed, true
+	case errors.Is(err, domain.ErrTokenSignatureInvalid):
+		return model.TokenInvalidReasonSignature, true
+	case errors.Is(err, domain.ErrTokenExpired):
+		return model.TokenInvalidReasonExpired, true
+	case errors.Is(err, domain.ErrTokenNotYetValid):
+		return model.TokenInvalidReasonNotYet, true
+	case errors.Is(err, domain.ErrTokenIssuerInvalid):
+		return model.TokenInvalidReasonIssuer, true
+	case errors.Is(err, domain.ErrTokenAudienceInvalid):
+		return model.TokenInvalidReasonAudience, true
+	case errors.Is(err, domain.ErrTokenRevoked):
+		return model.TokenInvalidReasonRevoked, true
+	case errors.Is(err, domain.ErrTokenInvalid):
+		return model.TokenInvalidReasonInvalid, true
+	default:
+		return model.TokenInvalidReasonNone, false
+	}
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
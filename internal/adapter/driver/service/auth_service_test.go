@@ -96,3 +96,75 @@ func TestAuthService_CreateToken(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthService_ValidateToken(t *testing.T) {
+	secret := "test-secret"
+	tokenService := driven.NewTokenService(secret, time.Hour)
+	subject := model.Subject{UserId: "123", IsAdmin: true}
+
+	validToken, err := tokenService.CreateToken(subject)
+	assert.NoError(t, err)
+
+	expiredToken, err := driven.NewTokenService(secret, -time.Minute).CreateToken(subject)
+	assert.NoError(t, err)
+
+	foreignToken, err := driven.NewTokenService("other-secret", time.Hour).CreateToken(subject)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		token          string
+		expectValid    bool
+		expectedReason model.TokenInvalidReason
+		expectAnyError bool
+	}{
+		{
+			name:        "valid token",
+			token:       validToken,
+			expectValid: true,
+		},
+		{
+			name:           "expired token",
+			token:          expiredToken,
+			expectedReason: model.TokenInvalidReasonExpired,
+		},
+		{
+			name:           "token signed with another secret",
+			token:          foreignToken,
+			expectedReason: model.TokenInvalidReasonSignature,
+		},
+		{
+			name:           "malformed token",
+			token:          "not-a-token",
+			expectedReason: model.TokenInvalidReasonMalformed,
+		},
+		{
+			name:           "error on validation failure",
+			token:          "",
+			expectAnyError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService := NewAuthService(driven.NewMockUserService(), tokenService)
+
+			response, err := authService.ValidateToken(context.Background(), model.ValidateTokenRequest{Token: tt.token})
+			if tt.expectAnyError {
+				assert.Error(t, err)
+				assert.Nil(t, response)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectValid, response.Valid)
+			assert.Equal(t, tt.expectedReason, response.Reason)
+			if tt.expectValid {
+				assert.Equal(t, subject, response.Subject)
+				assert.NotEmpty(t, response.Identity)
+				assert.False(t, response.IssuedAt.IsZero())
+				assert.True(t, response.ExpiresAt.After(response.IssuedAt))
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package driver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+const (
+	authCodeBytes = 32
+	// codeChallengeLength is the length of a base64url encoded SHA-256 digest.
+	codeChallengeLength = 43
+	// A code verifier has 43 to 128 unreserved characters (RFC 7636 section 4.1).
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+// CheckAuthorizeRequest validates an authorization request before the login
+// form is shown. ErrInvalidClient and ErrRedirectUriInvalid mean the request
+// must not be redirected back to the client; any other error is reported to
+// the client through the redirect URI. Scopes depend on the role of the user
+// and are only checked by Authorize.
+func (as AuthService) CheckAuthorizeRequest(ctx context.Context, dto model.AuthorizeRequest) error {
+	if err := dto.Validate(ctx); err != nil {
+		return err
+	}
+
+	if as.clients == nil || as.authCodes == nil {
+		return domain.ErrUnsupported
+	}
+
+	client, err := as.clients.GetClient(ctx, dto.ClientId)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return domain.ErrInvalidClient
+	}
+	if !client.HasRedirectUri(dto.RedirectUri) {
+		return domain.ErrRedirectUriInvalid
+	}
+	if err := checkClientScopes(client, dto.Scopes); err != nil {
+		return err
+	}
+
+	if dto.ResponseType != "code" {
+		return domain.ErrResponseTypeInvalid
+	}
+	if dto.CodeChallengeMethod != model.CodeChallengeMethodS256 || len(dto.CodeChallenge) != codeChallengeLength {
+		return domain.ErrCodeChallengeInvalid
+	}
+	return nil
+}
+
+// Authorize authenticates the user of an authorization request and issues a
+// short-lived, single-use authorization code bound to the client, the
+// redirect URI and the PKCE challenge. Users with MFA get a challenge for
+// AuthorizeMfa instead.
+func (as AuthService) Authorize(ctx context.Context, dto model.AuthorizeLoginRequest) (*model.AuthorizeResponse, error) {
+	if err := as.CheckAuthorizeRequest(ctx, dto.Authorization); err != nil {
+		return nil, err
+	}
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := as.checkCredentials(ctx, model.LoginAttempt{Username: dto.Username, ClientIp: dto.ClientIp}, dto.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	request := dto.Authorization
+	client, err := as.clients.GetClient(ctx, request.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domain.ErrInvalidClient
+	}
+	subject, err := as.userSubject(ctx, user, request.Scopes, client)
+	if err != nil {
+		return nil, err
+	}
+	authTime := time.Now()
+
+	challenge, err := as.startMfa(ctx, model.MfaChallenge{Subject: subject, Username: dto.Username, ClientId: request.ClientId, Nonce: request.Nonce, AuthTime: authTime})
+	if err != nil {
+		return nil, err
+	}
+	if challenge != "" {
+		return &model.AuthorizeResponse{MfaChallenge: challenge}, nil
+	}
+
+	return as.issueAuthorizationCode(ctx, request, subject, authTime)
+}
+
+// AuthorizeMfa completes the login of an authorization request that Authorize
+// answered with an MFA challenge, and issues the authorization code.
+func (as AuthService) AuthorizeMfa(ctx context.Context, dto model.AuthorizeMfaRequest) (*model.AuthorizeResponse, error) {
+	if err := as.CheckAuthorizeRequest(ctx, dto.Authorization); err != nil {
+		return nil, err
+	}
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if as.mfaStore == nil {
+		return nil, domain.ErrUnsupported
+	}
+
+	challenge, err := as.takeMfaChallenge(ctx, dto.Challenge, dto.Code, dto.ClientIp)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.ClientId != dto.Authorization.ClientId {
+		return nil, domain.ErrMfaChallengeInvalid
+	}
+
+	return as.issueAuthorizationCode(ctx, dto.Authorization, challenge.Subject, challenge.AuthTime)
+}
+
+// issueAuthorizationCode stores a new code for request and subject.
+func (as AuthService) issueAuthorizationCode(ctx context.Context, request model.AuthorizeRequest, subject model.Subject, authTime time.Time) (*model.AuthorizeResponse, error) {
+	raw := make([]byte, authCodeBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	code := base64.RawURLEncoding.EncodeToString(raw)
+
+	err := as.authCodes.Save(ctx, model.AuthorizationCode{
+		Hash:          hashOpaqueToken(code),
+		ClientId:      request.ClientId,
+		RedirectUri:   request.RedirectUri,
+		Subject:       subject,
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     time.Now().Add(as.authCodeExpireAfter),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AuthorizeResponse{Code: code}, nil
+}
+
+// ExchangeAuthorizationCode redeems an authorization code for tokens. The code
+// verifier must match the challenge of the authorization request, and
+// confidential clients must authenticate as well.
+func (as AuthService) ExchangeAuthorizationCode(ctx context.Context, dto model.AuthorizationCodeRequest) (*model.CreateTokenResponse, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if as.clients == nil || as.authCodes == nil {
+		return nil, domain.ErrUnsupported
+	}
+
+	client, err := as.tokenClient(ctx, dto.ClientId, dto.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// Take the code before checking it so a failed attempt burns it too.
+	code, err := as.authCodes.Take(ctx, hashOpaqueToken(dto.Code))
+	if err != nil {
+		return nil, err
+	}
+	if code == nil || !time.Now().Before(code.ExpiresAt) ||
+		code.ClientId != client.Id || code.RedirectUri != dto.RedirectUri ||
+		!verifyCodeChallenge(dto.CodeVerifier, code.CodeChallenge) {
+		return nil, domain.ErrAuthCodeInvalid
+	}
+
+	response, err := as.issueTokens(ctx, code.Subject, client.Id, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if model.HasScope(code.Subject.Scopes, model.ScopeOpenId) {
+		response.IdToken, err = as.createIdToken(ctx, code.Subject.UserId, client.Id, code.Nonce, code.AuthTime, code.Subject.Scopes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// validCodeVerifier reports whether verifier has the length and characters
+// RFC 7636 allows.
+func validCodeVerifier(verifier string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+	for _, c := range verifier {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// tokenClient authenticates the client redeeming a grant. Public clients only
+// name themselves, confidential ones need their secret.
+func (as AuthService) tokenClient(ctx context.Context, clientId, secret string) (*model.Client, error) {
+	client, err := as.clients.GetClient(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domain.ErrInvalidClient
+	}
+	if client.Public {
+		return client, nil
+	}
+	client, err = as.clients.Authenticate(ctx, clientId, secret)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domain.ErrInvalidClient
+	}
+	return client, nil
+}
+
+// verifyCodeChallenge checks a PKCE verifier against its S256 challenge.
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if !validCodeVerifier(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
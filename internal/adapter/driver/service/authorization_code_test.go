@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func newAuthorizingAuthService(t *testing.T) *AuthService {
+	registry, err := driven.NewMemoryClientRegistry(model.Client{Id: "spa", Public: true, Scopes: []string{model.ScopeOpenId, model.ScopeProfile}, RedirectUris: []string{"https://app.finman.test/callback"}})
+	assert.NoError(t, err)
+
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123"}, nil)
+	return NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithClientRegistry(registry),
+		WithAuthorizationCodes(driven.NewMemoryAuthorizationCodeStore(), time.Minute),
+	)
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorizeRequest() model.AuthorizeRequest {
+	return model.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientId:            "spa",
+		RedirectUri:         "https://app.finman.test/callback",
+		Scopes:              []string{model.ScopeOpenId},
+		Nonce:               "nonce",
+		CodeChallenge:       codeChallenge(testCodeVerifier),
+		CodeChallengeMethod: model.CodeChallengeMethodS256,
+	}
+}
+
+func TestAuthService_AuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	authService := newAuthorizingAuthService(t)
+
+	authorized, err := authService.Authorize(ctx, model.AuthorizeLoginRequest{Authorization: authorizeRequest(), Username: "user", Password: "pass"})
+	assert.NoError(t, err)
+
+	exchange := model.AuthorizationCodeRequest{
+		Code:         authorized.Code,
+		RedirectUri:  "https://app.finman.test/callback",
+		ClientId:     "spa",
+		CodeVerifier: testCodeVerifier,
+	}
+	response, err := authService.ExchangeAuthorizationCode(ctx, exchange)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Token)
+	assert.NotEmpty(t, response.RefreshToken)
+	assert.NotEmpty(t, response.IdToken)
+
+	// Codes are single use.
+	_, err = authService.ExchangeAuthorizationCode(ctx, exchange)
+	assert.ErrorIs(t, err, domain.ErrAuthCodeInvalid)
+}
+
+func TestAuthService_RefreshTokenBoundToClient(t *testing.T) {
+	ctx := context.Background()
+	authService := newAuthorizingAuthService(t)
+
+	authorized, err := authService.Authorize(ctx, model.AuthorizeLoginRequest{Authorization: authorizeRequest(), Username: "user", Password: "pass"})
+	assert.NoError(t, err)
+	response, err := authService.ExchangeAuthorizationCode(ctx, model.AuthorizationCodeRequest{
+		Code:         authorized.Code,
+		RedirectUri:  "https://app.finman.test/callback",
+		ClientId:     "spa",
+		CodeVerifier: testCodeVerifier,
+	})
+	assert.NoError(t, err)
+
+	// Only the client the token was issued to may refresh it.
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: response.RefreshToken})
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenInvalid)
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: response.RefreshToken, ClientId: "unknown"})
+	assert.ErrorIs(t, err, domain.ErrInvalidClient)
+
+	refreshed, err := authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: response.RefreshToken, ClientId: "spa"})
+	assert.NoError(t, err)
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: refreshed.RefreshToken, ClientId: "spa"})
+	assert.NoError(t, err)
+}
+
+func TestAuthService_AuthorizationCodeFailures(t *testing.T) {
+	ctx := context.Background()
+	authService := newAuthorizingAuthService(t)
+
+	request := authorizeRequest()
+	request.RedirectUri = "https://evil.test/callback"
+	assert.ErrorIs(t, authService.CheckAuthorizeRequest(ctx, request), domain.ErrRedirectUriInvalid)
+
+	request = authorizeRequest()
+	request.ClientId = "unknown"
+	assert.ErrorIs(t, authService.CheckAuthorizeRequest(ctx, request), domain.ErrInvalidClient)
+
+	request = authorizeRequest()
+	request.CodeChallengeMethod = "plain"
+	assert.ErrorIs(t, authService.CheckAuthorizeRequest(ctx, request), domain.ErrCodeChallengeInvalid)
+
+	request = authorizeRequest()
+	request.ResponseType = "token"
+	assert.ErrorIs(t, authService.CheckAuthorizeRequest(ctx, request), domain.ErrResponseTypeInvalid)
+
+	// Scopes the client is not registered for are refused before the login.
+	request = authorizeRequest()
+	request.Scopes = []string{"transactions:write"}
+	assert.ErrorIs(t, authService.CheckAuthorizeRequest(ctx, request), domain.ErrScopeInvalid)
+
+	authorized, err := authService.Authorize(ctx, model.AuthorizeLoginRequest{Authorization: authorizeRequest(), Username: "user", Password: "pass"})
+	assert.NoError(t, err)
+	_, err = authService.ExchangeAuthorizationCode(ctx, model.AuthorizationCodeRequest{
+		Code:         authorized.Code,
+		RedirectUri:  "https://app.finman.test/callback",
+		ClientId:     "spa",
+		CodeVerifier: strings.Repeat("a", 43),
+	})
+	assert.ErrorIs(t, err, domain.ErrAuthCodeInvalid)
+
+	// Verifiers must be 43 to 128 unreserved characters, even when they hash
+	// to the challenge.
+	for _, verifier := range []string{"too-short", strings.Repeat("a", 129), strings.Repeat("a", 42) + "/"} {
+		request = authorizeRequest()
+		request.CodeChallenge = codeChallenge(verifier)
+		authorized, err = authService.Authorize(ctx, model.AuthorizeLoginRequest{Authorization: request, Username: "user", Password: "pass"})
+		assert.NoError(t, err)
+		_, err = authService.ExchangeAuthorizationCode(ctx, model.AuthorizationCodeRequest{
+			Code:         authorized.Code,
+			RedirectUri:  "https://app.finman.test/callback",
+			ClientId:     "spa",
+			CodeVerifier: verifier,
+		})
+		assert.ErrorIs(t, err, domain.ErrAuthCodeInvalid, verifier)
+	}
+}
@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// CreateClientToken issues an access token to a confidential client acting on
+// its own behalf (the OAuth2 client_credentials grant). No refresh token is
+// issued, the client can simply authenticate again.
+func (as AuthService) CreateClientToken(ctx context.Context, dto model.ClientCredentialsRequest) (*model.CreateTokenResponse, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if as.clients == nil {
+		return nil, domain.ErrUnsupported
+	}
+
+	client, err := as.clients.Authenticate(ctx, dto.ClientId, dto.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domain.ErrInvalidClient
+	}
+
+	scopes := client.Scopes
+	if len(dto.Scopes) > 0 {
+		for _, scope := range dto.Scopes {
+			if !client.HasScope(scope) {
+				return nil, domain.ErrScopeInvalid
+			}
+		}
+		scopes = dto.Scopes
+	}
+
+	subject := model.Subject{ClientId: client.Id, Scopes: scopes}
+	token, err := as.signAccessToken(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CreateTokenResponse{Token: token, ExpiresIn: as.tokenService.ExpireAfter(), Scopes: scopes}, nil
+}
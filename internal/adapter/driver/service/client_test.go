@@ -0,0 +1,51 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthService_CreateClientToken(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registry, err := driven.NewMemoryClientRegistry(model.Client{Id: "reports", SecretHash: string(hash), Scopes: []string{"reports:read", "reports:write"}})
+	assert.NoError(t, err)
+
+	tokenService := driven.NewTokenService("test-secret", time.Hour)
+	authService := NewAuthService(driven.NewMockUserService(), tokenService,
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithClientRegistry(registry),
+	)
+
+	response, err := authService.CreateClientToken(ctx, model.ClientCredentialsRequest{ClientId: "reports", ClientSecret: "s3cret", Scopes: []string{"reports:read"}})
+	assert.NoError(t, err)
+	assert.Empty(t, response.RefreshToken)
+	assert.Equal(t, time.Hour, response.ExpiresIn)
+	assert.Equal(t, []string{"reports:read"}, response.Scopes)
+
+	validated, err := authService.ValidateToken(ctx, model.ValidateTokenRequest{Token: response.Token})
+	assert.NoError(t, err)
+	assert.True(t, validated.Valid)
+	assert.Equal(t, model.Subject{ClientId: "reports", Scopes: []string{"reports:read"}}, validated.Subject)
+
+	response, err = authService.CreateClientToken(ctx, model.ClientCredentialsRequest{ClientId: "reports", ClientSecret: "s3cret"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"reports:read", "reports:write"}, response.Scopes)
+
+	_, err = authService.CreateClientToken(ctx, model.ClientCredentialsRequest{ClientId: "reports", ClientSecret: "s3cret", Scopes: []string{"admin"}})
+	assert.ErrorIs(t, err, domain.ErrScopeInvalid)
+
+	_, err = authService.CreateClientToken(ctx, model.ClientCredentialsRequest{ClientId: "reports", ClientSecret: "wrong"})
+	assert.ErrorIs(t, err, domain.ErrInvalidClient)
+
+	_, err = NewAuthService(driven.NewMockUserService(), tokenService).CreateClientToken(ctx, model.ClientCredentialsRequest{ClientId: "reports", ClientSecret: "s3cret"})
+	assert.ErrorIs(t, err, domain.ErrUnsupported)
+}
@@ -0,0 +1,113 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// checkCredentials looks up the user for username and password. Every failure
+// except an outage of the user service is reported as ErrInvalidAuth so
+// callers cannot tell an unknown user from a wrong password; the real cause
+// is only logged. For users with MFA a correct password does not reset the
+// throttler, only the second factor does.
+func (as AuthService) checkCredentials(ctx context.Context, attempt model.LoginAttempt, password string) (*model.GetUserResponse, error) {
+	start := time.Now()
+	defer as.waitMinLoginDuration(ctx, start)
+
+	if as.throttler != nil {
+		if err := as.throttler.Allow(ctx, attempt); err != nil {
+			slog.WarnContext(ctx, "Login rejected", "username", attempt.Username, "client_ip", attempt.ClientIp, "error", err)
+			as.metrics.CountLogin(model.LoginOutcomeThrottled)
+			return nil, err
+		}
+	}
+
+	user, err := as.userService.GetUser(ctx, attempt.Username, password)
+	switch {
+	case errors.Is(err, domain.ErrUserServiceUnavailable):
+		slog.ErrorContext(ctx, "Login failed", "username", attempt.Username, "error", err)
+		as.recordLogin(ctx, attempt, model.LoginOutcomeUnavailable)
+		as.metrics.CountLogin(model.LoginOutcomeUnavailable)
+		return nil, domain.ErrUserServiceUnavailable
+	case err != nil:
+		slog.WarnContext(ctx, "Login failed: user service rejected the credentials", "username", attempt.Username, "client_ip", attempt.ClientIp, "error", err)
+		as.recordLogin(ctx, attempt, model.LoginOutcomeInvalidCredentials)
+		as.metrics.CountLogin(model.LoginOutcomeInvalidCredentials)
+		return nil, domain.ErrInvalidAuth
+	case user == nil:
+		slog.WarnContext(ctx, "Login failed: unknown user or wrong password", "username", attempt.Username, "client_ip", attempt.ClientIp)
+		as.recordLogin(ctx, attempt, model.LoginOutcomeInvalidCredentials)
+		as.metrics.CountLogin(model.LoginOutcomeInvalidCredentials)
+		return nil, domain.ErrInvalidAuth
+	}
+	outcome := model.LoginOutcomeSuccess
+	if enrolled, err := as.mfaEnrolled(ctx, user.Id); err != nil || enrolled {
+		outcome = model.LoginOutcomeMfaRequired
+	}
+	as.recordLogin(ctx, attempt, outcome)
+	as.metrics.CountLogin(outcome)
+	return user, nil
+}
+
+// recordLogin feeds the outcome of a credential check to the throttler. A
+// broken throttler must not block logins, so errors are only logged. Outcomes
+// that are neither a success nor a failure only end the attempt.
+func (as AuthService) recordLogin(ctx context.Context, attempt model.LoginAttempt, outcome model.LoginOutcome) {
+	if as.throttler == nil {
+		return
+	}
+	var err error
+	switch outcome {
+	case model.LoginOutcomeSuccess:
+		err = as.throttler.Succeeded(ctx, attempt)
+	case model.LoginOutcomeInvalidCredentials:
+		err = as.throttler.Failed(ctx, attempt)
+	default:
+		err = as.throttler.Released(ctx, attempt)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording login attempt", "error", err)
+	}
+}
+
+// UnlockAccount clears the failed logins and any lockout of a username. Only
+// admins may call it.
+func (as AuthService) UnlockAccount(ctx context.Context, dto model.UnlockAccountRequest) error {
+	if err := dto.Validate(ctx); err != nil {
+		return err
+	}
+
+	if as.throttler == nil {
+		return domain.ErrUnsupported
+	}
+
+	_, caller, err := as.authenticate(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+	if !caller.IsAdmin {
+		return domain.ErrPermissionDenied
+	}
+
+	return as.throttler.Unlock(ctx, dto.Username)
+}
+
+// waitMinLoginDuration blocks until at least the configured minimum login
+// duration has passed since start, so every outcome takes about as long.
+func (as AuthService) waitMinLoginDuration(ctx context.Context, start time.Time) {
+	remaining := as.minLoginDuration - time.Since(start)
+	if remaining <= 0 {
+		return
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
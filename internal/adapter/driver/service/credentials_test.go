@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthService_CreateTokenNormalizesFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *model.GetUserResponse
+		err      error
+		expected error
+	}{
+		{name: "unknown user", err: status.Error(codes.NotFound, "user not found"), expected: domain.ErrInvalidAuth},
+		{name: "wrong password", expected: domain.ErrInvalidAuth},
+		{name: "backend error", err: errors.New("pq: connection reset"), expected: domain.ErrInvalidAuth},
+		{name: "outage", err: fmt.Errorf("%w: connection refused", domain.ErrUserServiceUnavailable), expected: domain.ErrUserServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userService := driven.NewMockUserService()
+			userService.SetGetUserResponse(tt.response, tt.err)
+			authService := NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour))
+
+			_, err := authService.CreateToken(context.Background(), model.CreateTokenRequest{Username: "user", Password: "pass"})
+			// Compare identity so no detail of the cause leaks to the caller.
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestAuthService_CreateTokenMinDuration(t *testing.T) {
+	minDuration := 50 * time.Millisecond
+
+	for _, user := range []*model.GetUserResponse{nil, {Id: "123"}} {
+		userService := driven.NewMockUserService()
+		userService.SetGetUserResponse(user, nil)
+		authService := NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour), WithMinLoginDuration(minDuration))
+
+		start := time.Now()
+		authService.CreateToken(context.Background(), model.CreateTokenRequest{Username: "user", Password: "pass"})
+		assert.GreaterOrEqual(t, time.Since(start), minDuration)
+	}
+}
+
+func TestAuthService_UnlockAccount(t *testing.T) {
+	ctx := context.Background()
+	tokenService := driven.NewTokenService("test-secret", time.Hour)
+	throttler := driven.NewLoginThrottler(driven.ThrottleConfig{LockoutThreshold: 2, LockoutDuration: time.Hour}, driven.NewMemoryLockoutStore(time.Hour))
+
+	newService := func(user *model.GetUserResponse) *AuthService {
+		userService := driven.NewMockUserService()
+		userService.SetGetUserResponse(user, nil)
+		return NewAuthService(userService, tokenService,
+			WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+			WithLoginThrottler(throttler),
+		)
+	}
+	rejecting := newService(nil)
+	user := newService(&model.GetUserResponse{Id: "123"})
+	admin := newService(&model.GetUserResponse{Id: "1", IsAdmin: true})
+
+	attempt := model.CreateTokenRequest{Username: "victim", Password: "guess"}
+	for i := 0; i < 2; i++ {
+		_, err := rejecting.CreateToken(ctx, attempt)
+		assert.Equal(t, domain.ErrInvalidAuth, err)
+	}
+	// A locked account stays locked even for the right password.
+	_, err := user.CreateToken(ctx, attempt)
+	assert.ErrorIs(t, err, domain.ErrAccountLocked)
+
+	err = user.UnlockAccount(ctx, model.UnlockAccountRequest{Token: login(t, user).Token, Username: "victim"})
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	err = admin.UnlockAccount(ctx, model.UnlockAccountRequest{Token: login(t, admin).Token, Username: "victim"})
+	assert.NoError(t, err)
+	_, err = user.CreateToken(ctx, attempt)
+	assert.NoError(t, err)
+
+	unthrottled := NewAuthService(driven.NewMockUserService(), tokenService)
+	err = unthrottled.UnlockAccount(ctx, model.UnlockAccountRequest{Token: "token", Username: "victim"})
+	assert.ErrorIs(t, err, domain.ErrUnsupported)
+}
@@ -0,0 +1,31 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// countTokenValidation counts the outcome of checking a token; err is the
+// validation error or nil. Failures that say nothing about the token, such
+// as an unreachable revocation store, are not counted.
+func (as AuthService) countTokenValidation(err error) {
+	if err == nil {
+		as.metrics.CountTokenValidation(model.TokenInvalidReasonNone)
+		return
+	}
+	if reason, ok := tokenInvalidReason(err); ok {
+		as.metrics.CountTokenValidation(reason)
+	}
+}
+
+// nopMetrics is used when no metrics are configured.
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveServerCall(method, code string, duration time.Duration)      {}
+func (nopMetrics) ObserveUserServiceCall(method, code string, duration time.Duration) {}
+func (nopMetrics) CountLogin(outcome model.LoginOutcome)                              {}
+func (nopMetrics) CountTokenIssued(kind model.TokenKind)                              {}
+func (nopMetrics) CountTokenValidation(reason model.TokenInvalidReason)               {}
+func (nopMetrics) CountUserServiceRetry(method string)                                {}
+func (nopMetrics) SetUserServiceCircuitState(state model.CircuitState)                {}
@@ -0,0 +1,46 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthService_Metrics(t *testing.T) {
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123"}, nil)
+	metrics := driven.NewMemoryMetrics()
+	as := NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithMetrics(metrics),
+	)
+	ctx := context.Background()
+
+	response := login(t, as)
+	assert.Equal(t, 1, metrics.Logins(model.LoginOutcomeSuccess))
+	assert.Equal(t, 1, metrics.TokensIssued(model.TokenKindAccess))
+	assert.Equal(t, 1, metrics.TokensIssued(model.TokenKindRefresh))
+
+	userService.SetGetUserResponse(nil, nil)
+	_, err := as.CreateToken(ctx, model.CreateTokenRequest{Username: "validUser", Password: "wrong"})
+	assert.ErrorIs(t, err, domain.ErrInvalidAuth)
+	userService.SetGetUserResponse(nil, domain.ErrUserServiceUnavailable)
+	_, err = as.CreateToken(ctx, model.CreateTokenRequest{Username: "validUser", Password: "validPass"})
+	assert.ErrorIs(t, err, domain.ErrUserServiceUnavailable)
+	assert.Equal(t, 1, metrics.Logins(model.LoginOutcomeInvalidCredentials))
+	assert.Equal(t, 1, metrics.Logins(model.LoginOutcomeUnavailable))
+
+	_, err = as.ValidateToken(ctx, model.ValidateTokenRequest{Token: response.Token})
+	assert.NoError(t, err)
+	_, err = as.ValidateToken(ctx, model.ValidateTokenRequest{Token: "garbage"})
+	assert.NoError(t, err)
+	_, err = as.ValidateToken(ctx, model.ValidateTokenRequest{Token: "garbage"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, metrics.TokenValidations(model.TokenInvalidReasonNone))
+	assert.Equal(t, 2, metrics.TokenValidations(model.TokenInvalidReasonMalformed))
+}
@@ -0,0 +1,290 @@
+package driver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+const (
+	mfaChallengeBytes = 32
+	// mfaMaxAttempts wrong codes burn a challenge, so guessing a code needs
+	// the password again every few tries.
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollMfa starts a TOTP enrollment for the caller after checking the
+// password again. The secret only protects logins once ConfirmMfaEnrollment
+// accepted a code generated from it; until then enrolling again replaces it.
+func (as AuthService) EnrollMfa(ctx context.Context, dto model.EnrollMfaRequest) (*model.EnrollMfaResponse, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if as.mfaStore == nil {
+		return nil, domain.ErrUnsupported
+	}
+
+	userId, err := as.authenticateUser(ctx, dto.Token)
+	if err != nil {
+		return nil, err
+	}
+	username, err := as.reauthenticate(ctx, userId, dto.Password, dto.ClientIp)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment, err := as.mfaStore.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment != nil && enrollment.Confirmed {
+		return nil, domain.ErrMfaAlreadyEnrolled
+	}
+
+	secret, err := as.totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = as.mfaStore.Save(ctx, model.MfaEnrollment{UserId: userId, Secret: secret, CreatedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.EnrollMfaResponse{Secret: secret, Uri: as.totp.Uri(secret, username)}, nil
+}
+
+// reauthenticate checks password of the user behind userId like a login, so
+// guesses are throttled, and returns the username.
+func (as AuthService) reauthenticate(ctx context.Context, userId, password, clientIp string) (string, error) {
+	user, err := as.userService.GetUserById(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", domain.ErrPermissionDenied
+	}
+
+	checked, err := as.checkCredentials(ctx, model.LoginAttempt{Username: user.Username, ClientIp: clientIp}, password)
+	if err != nil {
+		return "", err
+	}
+	if checked.Id != userId {
+		return "", domain.ErrInvalidAuth
+	}
+	return user.Username, nil
+}
+
+// ConfirmMfaEnrollment turns MFA on once the caller proves to have the secret
+// and returns a fresh set of single-use recovery codes.
+func (as AuthService) ConfirmMfaEnrollment(ctx context.Context, dto model.ConfirmMfaEnrollmentRequest) (*model.ConfirmMfaEnrollmentResponse, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if as.mfaStore == nil {
+		return nil, domain.ErrUnsupported
+	}
+
+	userId, err := as.authenticateUser(ctx, dto.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment, err := as.mfaStore.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil {
+		return nil, domain.ErrMfaNotEnrolled
+	}
+	if enrollment.Confirmed {
+		return nil, domain.ErrMfaAlreadyEnrolled
+	}
+
+	step, ok := as.totp.Verify(enrollment.Secret, dto.Code)
+	if !ok {
+		return nil, domain.ErrMfaCodeInvalid
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashOpaqueToken(normalizeRecoveryCode(code)))
+	}
+
+	enrollment.Confirmed = true
+	enrollment.LastStep = step
+	enrollment.RecoveryCodeHashes = hashes
+	if err := as.mfaStore.Save(ctx, *enrollment); err != nil {
+		return nil, err
+	}
+
+	return &model.ConfirmMfaEnrollmentResponse{RecoveryCodes: codes}, nil
+}
+
+// CompleteMfa exchanges the challenge returned by CreateToken and a TOTP or
+// recovery code for the tokens of the login.
+func (as AuthService) CompleteMfa(ctx context.Context, dto model.CompleteMfaRequest) (*model.CreateTokenResponse, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if as.mfaStore == nil {
+		return nil, domain.ErrUnsupported
+	}
+
+	challenge, err := as.takeMfaChallenge(ctx, dto.Challenge, dto.Code, dto.ClientIp)
+	if err != nil {
+		return nil, err
+	}
+
+	return as.issueLoginTokens(ctx, challenge.Subject, challenge.ClientId, challenge.Nonce, challenge.AuthTime)
+}
+
+// startMfa creates an MFA challenge for a login whose password was accepted.
+// It returns an empty challenge when the user has not enabled MFA, in which
+// case the login is complete.
+func (as AuthService) startMfa(ctx context.Context, login model.MfaChallenge) (string, error) {
+	enrolled, err := as.mfaEnrolled(ctx, login.Subject.UserId)
+	if err != nil || !enrolled {
+		return "", err
+	}
+
+	raw := make([]byte, mfaChallengeBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(raw)
+
+	login.Hash = hashOpaqueToken(challenge)
+	login.ExpiresAt = login.AuthTime.Add(as.mfaExpireAfter)
+	if err := as.mfaChallenges.Save(ctx, login); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// mfaEnrolled reports whether userId has to pass a second factor.
+func (as AuthService) mfaEnrolled(ctx context.Context, userId string) (bool, error) {
+	if as.mfaStore == nil {
+		return false, nil
+	}
+	enrollment, err := as.mfaStore.Get(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+	return enrollment != nil && enrollment.Confirmed, nil
+}
+
+// takeMfaChallenge redeems challenge with code sent from clientIp. Codes go
+// through the login throttler like passwords, so every wrong code counts as
+// a failed login of the user and the IP, and only a right one resets the
+// failures. A wrong code puts the challenge back until mfaMaxAttempts is
+// reached.
+func (as AuthService) takeMfaChallenge(ctx context.Context, challenge, code, clientIp string) (*model.MfaChallenge, error) {
+	stored, err := as.mfaChallenges.Take(ctx, hashOpaqueToken(challenge))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || !time.Now().Before(stored.ExpiresAt) {
+		return nil, domain.ErrMfaChallengeInvalid
+	}
+
+	attempt := model.LoginAttempt{Username: stored.Username, ClientIp: clientIp}
+	if as.throttler != nil {
+		if err := as.throttler.Allow(ctx, attempt); err != nil {
+			slog.WarnContext(ctx, "One-time code rejected", "username", attempt.Username, "client_ip", clientIp, "error", err)
+			as.metrics.CountLogin(model.LoginOutcomeThrottled)
+			// The code was not checked, so the challenge stays usable.
+			if saveErr := as.mfaChallenges.Save(ctx, *stored); saveErr != nil {
+				return nil, saveErr
+			}
+			return nil, err
+		}
+	}
+
+	ok, err := as.verifyMfaCode(ctx, stored.Subject.UserId, code)
+	if err != nil {
+		as.recordLogin(ctx, attempt, model.LoginOutcomeUnavailable)
+		return nil, err
+	}
+	if !ok {
+		slog.WarnContext(ctx, "Login failed: wrong one-time code", "username", attempt.Username, "client_ip", clientIp)
+		as.recordLogin(ctx, attempt, model.LoginOutcomeInvalidCredentials)
+		as.metrics.CountLogin(model.LoginOutcomeInvalidCredentials)
+		stored.Attempts++
+		if stored.Attempts < mfaMaxAttempts {
+			if err := as.mfaChallenges.Save(ctx, *stored); err != nil {
+				return nil, err
+			}
+		}
+		return nil, domain.ErrMfaCodeInvalid
+	}
+	as.recordLogin(ctx, attempt, model.LoginOutcomeSuccess)
+	as.metrics.CountLogin(model.LoginOutcomeSuccess)
+	return stored, nil
+}
+
+// verifyMfaCode checks a TOTP code, which may not be reused, or one of the
+// recovery codes of userId, which is used up.
+func (as AuthService) verifyMfaCode(ctx context.Context, userId, code string) (bool, error) {
+	enrollment, err := as.mfaStore.Get(ctx, userId)
+	if err != nil || enrollment == nil || !enrollment.Confirmed {
+		return false, err
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := as.totp.Verify(enrollment.Secret, code); ok {
+		return as.mfaStore.UseStep(ctx, userId, step)
+	}
+	return as.mfaStore.UseRecoveryCode(ctx, userId, hashOpaqueToken(normalizeRecoveryCode(code)))
+}
+
+// authenticateUser returns the user a token was issued to. Client tokens are
+// rejected because clients have no second factor.
+func (as AuthService) authenticateUser(ctx context.Context, token string) (string, error) {
+	_, subject, err := as.authenticate(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	if subject.UserId == "" {
+		return "", domain.ErrPermissionDenied
+	}
+	return subject.UserId, nil
+}
+
+// generateRecoveryCode returns a random code like "abcd-efgh-ijkl-mnop".
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces the user may type.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
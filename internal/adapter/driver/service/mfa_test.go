@@ -0,0 +1,161 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTotp accepts code for the current step only.
+type fakeTotp struct {
+	code string
+	step int64
+}
+
+func (f *fakeTotp) GenerateSecret() (string, error) { return "SECRET", nil }
+
+func (f *fakeTotp) Uri(secret, accountName string) string {
+	return "otpauth://totp/Finman:" + accountName + "?secret=" + secret
+}
+
+func (f *fakeTotp) Verify(secret, code string) (int64, bool) {
+	return f.step, secret == "SECRET" && code == f.code
+}
+
+func newMfaAuthService(totp *fakeTotp, opts ...Option) *AuthService {
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", Username: "validUser"}, nil)
+	opts = append([]Option{
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithMfa(totp, driven.NewMemoryMfaStore(), driven.NewMemoryMfaChallengeStore(), time.Minute),
+	}, opts...)
+	return NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour), opts...)
+}
+
+func TestAuthService_MfaEnrollment(t *testing.T) {
+	ctx := context.Background()
+	totp := &fakeTotp{code: "123456", step: 1}
+	authService := newMfaAuthService(totp)
+	token := login(t, authService).Token
+
+	_, err := authService.ConfirmMfaEnrollment(ctx, model.ConfirmMfaEnrollmentRequest{Token: token, Code: "123456"})
+	assert.ErrorIs(t, err, domain.ErrMfaNotEnrolled)
+
+	enrollment, err := authService.EnrollMfa(ctx, model.EnrollMfaRequest{Token: token, Password: "validPass"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET", enrollment.Secret)
+	assert.Equal(t, "otpauth://totp/Finman:validUser?secret=SECRET", enrollment.Uri)
+
+	// An unconfirmed enrollment does not affect logins yet.
+	login(t, authService)
+
+	_, err = authService.ConfirmMfaEnrollment(ctx, model.ConfirmMfaEnrollmentRequest{Token: token, Code: "000000"})
+	assert.ErrorIs(t, err, domain.ErrMfaCodeInvalid)
+	confirmed, err := authService.ConfirmMfaEnrollment(ctx, model.ConfirmMfaEnrollmentRequest{Token: token, Code: "123456"})
+	assert.NoError(t, err)
+	assert.Len(t, confirmed.RecoveryCodes, recoveryCodeCount)
+	assert.Regexp(t, `^[a-z2-7]{4}(-[a-z2-7]{4}){3}$`, confirmed.RecoveryCodes[0])
+
+	_, err = authService.EnrollMfa(ctx, model.EnrollMfaRequest{Token: token, Password: "validPass"})
+	assert.ErrorIs(t, err, domain.ErrMfaAlreadyEnrolled)
+
+	// A token alone is not enough to enroll.
+	_, err = authService.EnrollMfa(ctx, model.EnrollMfaRequest{Token: token})
+	assert.Error(t, err)
+
+	unsupported := NewAuthService(driven.NewMockUserService(), driven.NewTokenService("test-secret", time.Hour))
+	_, err = unsupported.EnrollMfa(ctx, model.EnrollMfaRequest{Token: token, Password: "validPass"})
+	assert.ErrorIs(t, err, domain.ErrUnsupported)
+}
+
+func TestAuthService_CompleteMfa(t *testing.T) {
+	ctx := context.Background()
+	totp := &fakeTotp{code: "123456", step: 1}
+	authService := newMfaAuthService(totp)
+	token := login(t, authService).Token
+	_, err := authService.EnrollMfa(ctx, model.EnrollMfaRequest{Token: token, Password: "validPass"})
+	assert.NoError(t, err)
+	confirmed, err := authService.ConfirmMfaEnrollment(ctx, model.ConfirmMfaEnrollmentRequest{Token: token, Code: "123456"})
+	assert.NoError(t, err)
+
+	startLogin := func() string {
+		response, err := authService.CreateToken(ctx, model.CreateTokenRequest{Username: "validUser", Password: "validPass"})
+		assert.NoError(t, err)
+		assert.Empty(t, response.Token)
+		assert.Empty(t, response.RefreshToken)
+		assert.NotEmpty(t, response.MfaChallenge)
+		return response.MfaChallenge
+	}
+
+	// The code used to confirm the enrollment cannot be replayed.
+	challenge := startLogin()
+	_, err = authService.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: challenge, Code: "123456"})
+	assert.ErrorIs(t, err, domain.ErrMfaCodeInvalid)
+
+	totp.code, totp.step = "654321", 2
+	response, err := authService.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: challenge, Code: "654321"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Token)
+	assert.NotEmpty(t, response.RefreshToken)
+	assertReason(t, authService, response.Token, model.TokenInvalidReasonNone)
+
+	_, err = authService.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: challenge, Code: "654321"})
+	assert.ErrorIs(t, err, domain.ErrMfaChallengeInvalid)
+
+	// Recovery codes work once, however they are typed.
+	recoveryCode := confirmed.RecoveryCodes[0]
+	_, err = authService.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: startLogin(), Code: " " + recoveryCode + " "})
+	assert.NoError(t, err)
+	_, err = authService.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: startLogin(), Code: recoveryCode})
+	assert.ErrorIs(t, err, domain.ErrMfaCodeInvalid)
+
+	// Too many wrong codes burn the challenge.
+	challenge = startLogin()
+	for i := 0; i < mfaMaxAttempts; i++ {
+		_, err = authService.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: challenge, Code: "000000"})
+		assert.ErrorIs(t, err, domain.ErrMfaCodeInvalid)
+	}
+	totp.step = 3
+	_, err = authService.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: challenge, Code: "654321"})
+	assert.ErrorIs(t, err, domain.ErrMfaChallengeInvalid)
+}
+
+func TestAuthService_MfaThrottling(t *testing.T) {
+	ctx := context.Background()
+	totp := &fakeTotp{code: "123456", step: 1}
+	throttler := driven.NewLoginThrottler(driven.ThrottleConfig{LockoutThreshold: 3, LockoutDuration: time.Hour}, driven.NewMemoryLockoutStore(time.Hour))
+	authService := newMfaAuthService(totp, WithLoginThrottler(throttler))
+	token := login(t, authService).Token
+	_, err := authService.EnrollMfa(ctx, model.EnrollMfaRequest{Token: token, Password: "validPass"})
+	assert.NoError(t, err)
+	_, err = authService.ConfirmMfaEnrollment(ctx, model.ConfirmMfaEnrollmentRequest{Token: token, Code: "123456"})
+	assert.NoError(t, err)
+	totp.code, totp.step = "654321", 2
+
+	startLogin := func() string {
+		response, err := authService.CreateToken(ctx, model.CreateTokenRequest{Username: "validUser", Password: "validPass"})
+		assert.NoError(t, err)
+		return response.MfaChallenge
+	}
+	complete := func(challenge, code string) error {
+		_, err := authService.CompleteMfa(ctx, model.CompleteMfaRequest{Challenge: challenge, Code: code, ClientIp: "10.0.0.1"})
+		return err
+	}
+
+	// Wrong codes count as failed logins and a correct password alone does
+	// not clear them.
+	challenge := startLogin()
+	assert.ErrorIs(t, complete(challenge, "000000"), domain.ErrMfaCodeInvalid)
+	assert.ErrorIs(t, complete(challenge, "000001"), domain.ErrMfaCodeInvalid)
+	challenge = startLogin()
+	assert.ErrorIs(t, complete(challenge, "000002"), domain.ErrMfaCodeInvalid)
+
+	assert.ErrorIs(t, complete(challenge, "654321"), domain.ErrAccountLocked)
+	assert.NoError(t, throttler.Unlock(ctx, "validUser"))
+	assert.NoError(t, complete(challenge, "654321"))
+}
@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// userScopes are the scopes every user may request at login, on top of the
+// permissions of their role.
+var userScopes = []string{model.ScopeOpenId, model.ScopeProfile}
+
+// createIdToken issues an OpenID Connect ID token for userId. The claims are
+// read from the user service so they reflect the current profile.
+func (as AuthService) createIdToken(ctx context.Context, userId, clientId, nonce string, authTime time.Time, scopes []string) (string, error) {
+	user, err := as.userService.GetUserById(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", domain.ErrInvalidAuth
+	}
+
+	claims := model.IdTokenClaims{
+		Subject:  user.Id,
+		Audience: clientId,
+		Nonce:    nonce,
+		AuthTime: authTime,
+	}
+	if model.HasScope(scopes, model.ScopeProfile) {
+		claims.PreferredUsername = user.Username
+		claims.UpdatedAt = user.UpdatedAt
+	}
+	return as.signIdToken(ctx, claims)
+}
+
+// GetUserInfo returns the claims of the user an access token was issued to.
+// The token must have been granted the openid scope.
+func (as AuthService) GetUserInfo(ctx context.Context, dto model.UserInfoRequest) (*model.UserInfo, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	_, subject, err := as.authenticate(ctx, dto.Token)
+	if err != nil {
+		return nil, err
+	}
+	if subject.UserId == "" || !model.HasScope(subject.Scopes, model.ScopeOpenId) {
+		return nil, domain.ErrPermissionDenied
+	}
+
+	user, err := as.userService.GetUserById(ctx, subject.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrInvalidAuth
+	}
+
+	info := &model.UserInfo{Subject: user.Id}
+	if model.HasScope(subject.Scopes, model.ScopeProfile) {
+		info.PreferredUsername = user.Username
+		if !user.UpdatedAt.IsZero() {
+			info.UpdatedAt = user.UpdatedAt.Unix()
+		}
+	}
+	return info, nil
+}
+
+// GetOpenIdConfiguration returns the discovery document. It requires the
+// issuer to be the public base URL of the HTTP server.
+func (as AuthService) GetOpenIdConfiguration(ctx context.Context) (*model.OpenIdConfiguration, error) {
+	issuer := as.tokenService.Issuer()
+	u, err := url.Parse(issuer)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, domain.ErrUnsupported
+	}
+	base := strings.TrimSuffix(issuer, "/")
+
+	config := &model.OpenIdConfiguration{
+		Issuer:                            issuer,
+		TokenEndpoint:                     base + "/oauth/token",
+		UserInfoEndpoint:                  base + "/userinfo",
+		JwksUri:                           base + "/.well-known/jwks.json",
+		ScopesSupported:                   userScopes,
+		ResponseTypesSupported:            []string{},
+		GrantTypesSupported:               []string{"password", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{as.tokenService.Algorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "preferred_username", "updated_at"},
+	}
+	if as.clients != nil {
+		config.GrantTypesSupported = append(config.GrantTypesSupported, "client_credentials")
+	}
+	if as.clients != nil && as.authCodes != nil {
+		config.AuthorizationEndpoint = base + "/authorize"
+		config.ResponseTypesSupported = []string{"code"}
+		config.GrantTypesSupported = append(config.GrantTypesSupported, "authorization_code")
+		config.CodeChallengeMethodsSupported = []string{model.CodeChallengeMethodS256}
+		// Public clients only identify themselves and prove possession with PKCE.
+		config.TokenEndpointAuthMethodsSupported = append(config.TokenEndpointAuthMethodsSupported, "none")
+	}
+	return config, nil
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newOpenIdAuthService(t *testing.T, opts ...Option) *AuthService {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registry, err := driven.NewMemoryClientRegistry(
+		model.Client{Id: "web", SecretHash: string(hash), Scopes: []string{model.ScopeOpenId, model.ScopeProfile}},
+		model.Client{Id: "spa", Public: true, Scopes: []string{model.ScopeOpenId, model.ScopeProfile}, RedirectUris: []string{"https://app.finman.test/callback"}},
+	)
+	assert.NoError(t, err)
+
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", Username: "jane", UpdatedAt: time.Unix(1700000000, 0)}, nil)
+	tokenService := driven.NewTokenService("test-secret", time.Hour, driven.WithIssuer("https://auth.finman.test"))
+	return NewAuthService(userService, tokenService, append([]Option{WithClientRegistry(registry)}, opts...)...)
+}
+
+func TestAuthService_CreateTokenIdToken(t *testing.T) {
+	ctx := context.Background()
+	authService := newOpenIdAuthService(t)
+
+	response, err := authService.CreateToken(ctx, model.CreateTokenRequest{
+		Username:     "jane",
+		Password:     "pass",
+		Scopes:       []string{model.ScopeOpenId, model.ScopeProfile},
+		ClientId:     "web",
+		ClientSecret: "s3cret",
+		Nonce:        "n-0S6_WzA2Mj",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.IdToken)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(response.IdToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "123", claims["sub"])
+	assert.Equal(t, "https://auth.finman.test", claims["iss"])
+	assert.Equal(t, []interface{}{"web"}, claims["aud"])
+	assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.Equal(t, "jane", claims["preferred_username"])
+	assert.NotZero(t, claims["auth_time"])
+
+	response, err = authService.CreateToken(ctx, model.CreateTokenRequest{Username: "jane", Password: "pass"})
+	assert.NoError(t, err)
+	assert.Empty(t, response.IdToken)
+
+	_, err = authService.CreateToken(ctx, model.CreateTokenRequest{Username: "jane", Password: "pass", Scopes: []string{"email"}})
+	assert.ErrorIs(t, err, domain.ErrScopeInvalid)
+
+	// The audience of an ID token is never taken from an unauthenticated client.
+	for _, client := range []struct{ id, secret string }{{"web", ""}, {"web", "wrong"}, {"spa", ""}, {"unknown", "s3cret"}} {
+		_, err = authService.CreateToken(ctx, model.CreateTokenRequest{
+			Username:     "jane",
+			Password:     "pass",
+			Scopes:       []string{model.ScopeOpenId},
+			ClientId:     client.id,
+			ClientSecret: client.secret,
+		})
+		assert.ErrorIs(t, err, domain.ErrInvalidClient, client.id)
+	}
+}
+
+func TestAuthService_GetUserInfo(t *testing.T) {
+	ctx := context.Background()
+	authService := newOpenIdAuthService(t)
+
+	response, err := authService.CreateToken(ctx, model.CreateTokenRequest{Username: "jane", Password: "pass", Scopes: []string{model.ScopeOpenId}})
+	assert.NoError(t, err)
+	info, err := authService.GetUserInfo(ctx, model.UserInfoRequest{Token: response.Token})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UserInfo{Subject: "123"}, info)
+
+	response, err = authService.CreateToken(ctx, model.CreateTokenRequest{Username: "jane", Password: "pass", Scopes: []string{model.ScopeOpenId, model.ScopeProfile}})
+	assert.NoError(t, err)
+	info, err = authService.GetUserInfo(ctx, model.UserInfoRequest{Token: response.Token})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UserInfo{Subject: "123", PreferredUsername: "jane", UpdatedAt: 1700000000}, info)
+
+	response, err = authService.CreateToken(ctx, model.CreateTokenRequest{Username: "jane", Password: "pass"})
+	assert.NoError(t, err)
+	_, err = authService.GetUserInfo(ctx, model.UserInfoRequest{Token: response.Token})
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+}
+
+func TestAuthService_GetOpenIdConfiguration(t *testing.T) {
+	ctx := context.Background()
+
+	config, err := newOpenIdAuthService(t).GetOpenIdConfiguration(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://auth.finman.test", config.Issuer)
+	assert.Equal(t, "https://auth.finman.test/oauth/token", config.TokenEndpoint)
+	assert.Equal(t, "https://auth.finman.test/.well-known/jwks.json", config.JwksUri)
+	assert.Equal(t, []string{driven.AlgorithmHS256}, config.IdTokenSigningAlgValuesSupported)
+	// Without authorization codes the code flow is not advertised.
+	assert.Empty(t, config.AuthorizationEndpoint)
+	assert.Empty(t, config.ResponseTypesSupported)
+	assert.NotContains(t, config.GrantTypesSupported, "authorization_code")
+	assert.NotContains(t, config.TokenEndpointAuthMethodsSupported, "none")
+
+	config, err = newOpenIdAuthService(t, WithAuthorizationCodes(driven.NewMemoryAuthorizationCodeStore(), time.Minute)).GetOpenIdConfiguration(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://auth.finman.test/authorize", config.AuthorizationEndpoint)
+	assert.Contains(t, config.GrantTypesSupported, "authorization_code")
+
+	tokenService := driven.NewTokenService("test-secret", time.Hour, driven.WithIssuer("finman-auth-service"))
+	_, err = NewAuthService(driven.NewMockUserService(), tokenService).GetOpenIdConfiguration(ctx)
+	assert.ErrorIs(t, err, domain.ErrUnsupported)
+}
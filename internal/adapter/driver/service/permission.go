@@ -0,0 +1,44 @@
+package driver
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// CheckPermission decides whether the holder of a token may perform an action
+// on a resource, so services do not need authorization logic of their own.
+// The token must have been granted the permission before the policy rules are
+// asked, so narrowing the scopes of a token always narrows what it can do.
+func (as AuthService) CheckPermission(ctx context.Context, dto model.CheckPermissionRequest) (*model.Decision, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if as.policy == nil {
+		return nil, domain.ErrUnsupported
+	}
+
+	_, subject, err := as.authenticate(ctx, dto.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !model.GrantsPermission(subject.Scopes, dto.Resource.Type, dto.Action) {
+		slog.InfoContext(ctx, "Permission denied: not granted to the token", "action", dto.Action, "resource_type", dto.Resource.Type, "resource_id", dto.Resource.Id,
+			"user_id", subject.UserId, "client_id", subject.ClientId)
+		return &model.Decision{}, nil
+	}
+
+	decision, err := as.policy.Evaluate(ctx, model.AuthorizationRequest{Subject: subject, Action: dto.Action, Resource: dto.Resource})
+	if err != nil {
+		return nil, err
+	}
+	if !decision.Allowed {
+		slog.InfoContext(ctx, "Permission denied", "action", dto.Action, "resource_type", dto.Resource.Type, "resource_id", dto.Resource.Id,
+			"user_id", subject.UserId, "client_id", subject.ClientId, "rule", decision.Rule)
+	}
+	return &decision, nil
+}
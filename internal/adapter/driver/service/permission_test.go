@@ -0,0 +1,62 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthService_CheckPermission(t *testing.T) {
+	ctx := context.Background()
+	engine, err := driven.NewRulePolicyEngine(driven.PolicyRule{
+		Name: "owners-read", Effect: driven.EffectAllow, Roles: []string{"*"}, Actions: []string{"read"}, Resources: []string{"transaction"}, Owner: true,
+	})
+	assert.NoError(t, err)
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", RoleId: "clerk"}, nil)
+	authService := NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithPolicyEngine(engine),
+		WithRolePolicy(driven.NewMemoryRolePolicy(map[string][]string{"clerk": {"transaction:read", "report:read"}})),
+	)
+	token := login(t, authService).Token
+
+	decision, err := authService.CheckPermission(ctx, model.CheckPermissionRequest{Token: token, Action: "read", Resource: model.Resource{Type: "transaction", OwnerId: "123"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Decision{Allowed: true, Rule: "owners-read"}, decision)
+
+	decision, err = authService.CheckPermission(ctx, model.CheckPermissionRequest{Token: token, Action: "read", Resource: model.Resource{Type: "transaction", OwnerId: "456"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Decision{}, decision)
+
+	// A token narrowed to other scopes is denied even though the rule matches.
+	narrowed, err := authService.CreateToken(ctx, model.CreateTokenRequest{Username: "validUser", Password: "validPass", Scopes: []string{"report:read"}})
+	assert.NoError(t, err)
+	decision, err = authService.CheckPermission(ctx, model.CheckPermissionRequest{Token: narrowed.Token, Action: "read", Resource: model.Resource{Type: "transaction", OwnerId: "123"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Decision{}, decision)
+
+	_, err = authService.CheckPermission(ctx, model.CheckPermissionRequest{Token: "garbage", Action: "read", Resource: model.Resource{Type: "transaction"}})
+	assert.ErrorIs(t, err, domain.ErrTokenMalformed)
+
+	_, err = authService.CheckPermission(ctx, model.CheckPermissionRequest{Token: token, Action: "read"})
+	assert.Error(t, err)
+
+	_, err = NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour)).
+		CheckPermission(ctx, model.CheckPermissionRequest{Token: token, Action: "read", Resource: model.Resource{Type: "transaction"}})
+	assert.ErrorIs(t, err, domain.ErrUnsupported)
+}
+
+func TestGrantsPermission(t *testing.T) {
+	assert.True(t, model.GrantsPermission([]string{"transaction:read"}, "transaction", "read"))
+	assert.True(t, model.GrantsPermission([]string{"transaction:*"}, "transaction", "write"))
+	assert.True(t, model.GrantsPermission([]string{"*"}, "report", "read"))
+	assert.False(t, model.GrantsPermission([]string{"transaction:read"}, "transaction", "write"))
+	assert.False(t, model.GrantsPermission([]string{"report:*"}, "transaction", "read"))
+	assert.False(t, model.GrantsPermission(nil, "transaction", "read"))
+}
@@ -0,0 +1,143 @@
+package driver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+const refreshTokenBytes = 32
+
+// RefreshToken exchanges a refresh token for a new access/refresh pair. Each
+// refresh token can be used once; presenting one that was already rotated is
+// treated as theft and revokes its whole family. Only the client the token
+// was issued to may use it.
+func (as AuthService) RefreshToken(ctx context.Context, dto model.RefreshTokenRequest) (*model.CreateTokenResponse, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if as.refreshStore == nil {
+		return nil, domain.ErrRefreshTokenInvalid
+	}
+
+	var clientId string
+	if dto.ClientId != "" {
+		if as.clients == nil {
+			return nil, domain.ErrInvalidClient
+		}
+		client, err := as.tokenClient(ctx, dto.ClientId, dto.ClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		clientId = client.Id
+	}
+
+	hash := hashOpaqueToken(dto.RefreshToken)
+	stored, err := as.refreshStore.Get(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.Revoked || stored.ClientId != clientId {
+		return nil, domain.ErrRefreshTokenInvalid
+	}
+	if stored.IsRotated() {
+		return nil, as.revokeReusedFamily(ctx, stored.FamilyId)
+	}
+
+	now := time.Now()
+	if !now.Before(stored.ExpiresAt) {
+		return nil, domain.ErrRefreshTokenExpired
+	}
+
+	subject, err := as.refreshedSubject(ctx, stored.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if subject == nil {
+		// The user is gone; nothing of the family may be used any more.
+		if err := as.refreshStore.RevokeFamily(ctx, stored.FamilyId); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrRefreshTokenInvalid
+	}
+
+	rotated, err := as.refreshStore.MarkRotated(ctx, hash, now)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Another request rotated the same token concurrently.
+		return nil, as.revokeReusedFamily(ctx, stored.FamilyId)
+	}
+
+	return as.issueTokens(ctx, *subject, stored.ClientId, stored.FamilyId)
+}
+
+// refreshedSubject reads the user of a refresh token again, so deleted users
+// stop getting tokens and role changes apply on the next refresh. The scopes
+// stay those granted at login, less the ones the role no longer allows. It
+// returns nil when the user no longer exists.
+func (as AuthService) refreshedSubject(ctx context.Context, granted model.Subject) (*model.Subject, error) {
+	if granted.UserId == "" {
+		return &granted, nil
+	}
+	user, err := as.userService.GetUserById(ctx, granted.UserId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	permissions, err := as.rolePermissions(ctx, user.RoleId)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := model.Subject{UserId: user.Id, IsAdmin: user.IsAdmin, RoleId: user.RoleId, ClientId: granted.ClientId}
+	for _, scope := range granted.Scopes {
+		if model.HasScope(userScopes, scope) || model.HasScope(permissions, scope) {
+			subject.Scopes = append(subject.Scopes, scope)
+		}
+	}
+	return &subject, nil
+}
+
+func (as AuthService) revokeReusedFamily(ctx context.Context, familyId string) error {
+	if err := as.refreshStore.RevokeFamily(ctx, familyId); err != nil {
+		return err
+	}
+	return domain.ErrRefreshTokenReused
+}
+
+func (as AuthService) createRefreshToken(ctx context.Context, subject model.Subject, clientId, familyId string) (string, error) {
+	raw := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	err := as.refreshStore.Save(ctx, model.RefreshToken{
+		Hash:      hashOpaqueToken(token),
+		FamilyId:  familyId,
+		ClientId:  clientId,
+		Subject:   subject,
+		ExpiresAt: time.Now().Add(as.refreshExpireAfter),
+	})
+	if err != nil {
+		return "", err
+	}
+	as.metrics.CountTokenIssued(model.TokenKindRefresh)
+
+	return token, nil
+}
+
+// hashOpaqueToken derives the lookup key of a refresh token or authorization
+// code so the token itself is never stored.
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRefreshingAuthService(refreshExpireAfter time.Duration) *AuthService {
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", IsAdmin: true}, nil)
+	tokenService := driven.NewTokenService("test-secret", time.Hour)
+
+	return NewAuthService(userService, tokenService,
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), refreshExpireAfter),
+	)
+}
+
+func login(t *testing.T, as *AuthService) *model.CreateTokenResponse {
+	response, err := as.CreateToken(context.Background(), model.CreateTokenRequest{Username: "validUser", Password: "validPass"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.RefreshToken)
+	return response
+}
+
+func TestAuthService_RefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	authService := newRefreshingAuthService(time.Hour)
+	issued := login(t, authService)
+
+	rotated, err := authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: issued.RefreshToken})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rotated.Token)
+	assert.NotEqual(t, issued.RefreshToken, rotated.RefreshToken)
+
+	validation, err := authService.ValidateToken(ctx, model.ValidateTokenRequest{Token: rotated.Token})
+	assert.NoError(t, err)
+	assert.True(t, validation.Valid)
+	assert.Equal(t, model.Subject{UserId: "123", IsAdmin: true}, validation.Subject)
+
+	again, err := authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: rotated.RefreshToken})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, again.RefreshToken)
+}
+
+func TestAuthService_RefreshTokenReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	authService := newRefreshingAuthService(time.Hour)
+	issued := login(t, authService)
+	other := login(t, authService)
+
+	rotated, err := authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: issued.RefreshToken})
+	assert.NoError(t, err)
+
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: issued.RefreshToken})
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: rotated.RefreshToken})
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenInvalid)
+
+	// Families from other logins are unaffected.
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: other.RefreshToken})
+	assert.NoError(t, err)
+}
+
+func TestAuthService_RefreshTokenFailures(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		authService *AuthService
+		token       func(*AuthService) string
+		expectedErr error
+	}{
+		{
+			name:        "unknown token",
+			authService: newRefreshingAuthService(time.Hour),
+			token:       func(*AuthService) string { return "unknown" },
+			expectedErr: domain.ErrRefreshTokenInvalid,
+		},
+		{
+			name:        "expired token",
+			authService: newRefreshingAuthService(-time.Minute),
+			token:       func(as *AuthService) string { return login(t, as).RefreshToken },
+			expectedErr: domain.ErrRefreshTokenExpired,
+		},
+		{
+			name:        "refresh tokens disabled",
+			authService: NewAuthService(driven.NewMockUserService(), driven.NewTokenService("test-secret", time.Hour)),
+			token:       func(*AuthService) string { return "any" },
+			expectedErr: domain.ErrRefreshTokenInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := tt.authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: tt.token(tt.authService)})
+			assert.Nil(t, response)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestAuthService_RefreshTokenRereadsUser(t *testing.T) {
+	ctx := context.Background()
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", RoleId: "accountant"}, nil)
+	authService := NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithRolePolicy(driven.NewMemoryRolePolicy(map[string][]string{
+			"accountant": {"transactions:read", "transactions:write"},
+			"auditor":    {"transactions:read"},
+		})),
+	)
+	issued := login(t, authService)
+
+	// Role changes apply on the next refresh without adding scopes.
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", RoleId: "auditor"}, nil)
+	rotated, err := authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: issued.RefreshToken})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"transactions:read"}, rotated.Scopes)
+
+	// An outage of the user service keeps the token usable.
+	userService.SetGetUserResponse(nil, domain.ErrUserServiceUnavailable)
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: rotated.RefreshToken})
+	assert.ErrorIs(t, err, domain.ErrUserServiceUnavailable)
+
+	// A deleted user gets no more tokens.
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "456"}, nil)
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: rotated.RefreshToken})
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenInvalid)
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", RoleId: "auditor"}, nil)
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: rotated.RefreshToken})
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenInvalid)
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// Logout revokes the caller's access token and, when given, the refresh token
+// family it was issued with.
+func (as AuthService) Logout(ctx context.Context, dto model.LogoutRequest) error {
+	if err := dto.Validate(ctx); err != nil {
+		return err
+	}
+
+	if as.revocations == nil {
+		return domain.ErrUnsupported
+	}
+
+	claims, subject, err := as.authenticate(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+
+	// The token is accepted until exp plus the leeway, so it stays revoked
+	// that long.
+	if err := as.revocations.Revoke(ctx, claims.Identity, time.Unix(claims.ExpiresAt, 0).Add(as.tokenService.Leeway())); err != nil {
+		return err
+	}
+
+	if dto.RefreshToken == "" || as.refreshStore == nil {
+		return nil
+	}
+
+	stored, err := as.refreshStore.Get(ctx, hashOpaqueToken(dto.RefreshToken))
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.Subject.UserId != subject.UserId {
+		return nil
+	}
+
+	return as.refreshStore.RevokeFamily(ctx, stored.FamilyId)
+}
+
+// RevokeToken revokes any access token by its jti. Only admins may call it.
+func (as AuthService) RevokeToken(ctx context.Context, dto model.RevokeTokenRequest) error {
+	if err := dto.Validate(ctx); err != nil {
+		return err
+	}
+
+	if as.revocations == nil {
+		return domain.ErrUnsupported
+	}
+
+	_, caller, err := as.authenticate(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+	if !caller.IsAdmin {
+		return domain.ErrPermissionDenied
+	}
+
+	// The token itself is unknown here, so keep the entry for the longest
+	// lifetime a token could still have.
+	return as.revocations.Revoke(ctx, dto.Identity, as.revocationExpiry())
+}
+
+// RevokeAllForUser revokes every access and refresh token issued to a user so
+// far. Admins may target anyone, other callers only themselves.
+func (as AuthService) RevokeAllForUser(ctx context.Context, dto model.RevokeAllForUserRequest) error {
+	if err := dto.Validate(ctx); err != nil {
+		return err
+	}
+
+	if as.revocations == nil {
+		return domain.ErrUnsupported
+	}
+
+	_, caller, err := as.authenticate(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+	if !caller.IsAdmin && caller.UserId != dto.UserId {
+		return domain.ErrPermissionDenied
+	}
+
+	// The cut-off can be forgotten once every token it covers has expired.
+	if err := as.revocations.RevokeAllForUser(ctx, dto.UserId, time.Now(), as.revocationExpiry()); err != nil {
+		return err
+	}
+
+	if as.refreshStore == nil {
+		return nil
+	}
+	return as.refreshStore.RevokeUser(ctx, dto.UserId)
+}
+
+// revocationExpiry returns when a token issued before now is no longer
+// accepted, so revocations covering it can be forgotten.
+func (as AuthService) revocationExpiry() time.Time {
+	return time.Now().Add(as.tokenService.ExpireAfter() + as.tokenService.Leeway())
+}
+
+// authenticate verifies an access token presented by a caller.
+func (as AuthService) authenticate(ctx context.Context, token string) (model.StandardClaims, model.Subject, error) {
+	claims, err := as.verifyToken(ctx, token)
+	if err != nil {
+		as.countTokenValidation(err)
+		return claims, model.Subject{}, err
+	}
+
+	subject, err := as.tokenService.GetSubject(claims.Subject)
+	if err != nil {
+		as.countTokenValidation(domain.ErrTokenMalformed)
+		return claims, subject, domain.ErrTokenMalformed
+	}
+
+	as.countTokenValidation(nil)
+	return claims, subject, nil
+}
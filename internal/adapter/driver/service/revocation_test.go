@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRevokingAuthService(user *model.GetUserResponse, revocations *driven.MemoryRevocationStore) *AuthService {
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(user, nil)
+	tokenService := driven.NewTokenService("test-secret", time.Hour, driven.WithRevocationStore(revocations))
+
+	return NewAuthService(userService, tokenService,
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithRevocationStore(revocations),
+	)
+}
+
+func assertReason(t *testing.T, as *AuthService, token string, expected model.TokenInvalidReason) {
+	response, err := as.ValidateToken(context.Background(), model.ValidateTokenRequest{Token: token})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, response.Reason)
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	ctx := context.Background()
+	authService := newRevokingAuthService(&model.GetUserResponse{Id: "123"}, driven.NewMemoryRevocationStore())
+	issued := login(t, authService)
+
+	err := authService.Logout(ctx, model.LogoutRequest{Token: issued.Token, RefreshToken: issued.RefreshToken})
+	assert.NoError(t, err)
+
+	assertReason(t, authService, issued.Token, model.TokenInvalidReasonRevoked)
+
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: issued.RefreshToken})
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenInvalid)
+
+	err = authService.Logout(ctx, model.LogoutRequest{Token: issued.Token})
+	assert.ErrorIs(t, err, domain.ErrTokenRevoked)
+}
+
+func TestAuthService_LogoutCoversLeeway(t *testing.T) {
+	ctx := context.Background()
+	revocations := driven.NewMemoryRevocationStore()
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123"}, nil)
+	// The token expired ten seconds ago but is still inside the leeway.
+	now := time.Now().Add(-time.Hour - 10*time.Second)
+	tokenService := driven.NewTokenService("test-secret", time.Hour, driven.WithRevocationStore(revocations),
+		driven.WithLeeway(30*time.Second), driven.WithClock(func() time.Time { return now }))
+	authService := NewAuthService(userService, tokenService,
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithRevocationStore(revocations),
+	)
+	issued := login(t, authService)
+	now = time.Now()
+	assertReason(t, authService, issued.Token, model.TokenInvalidReasonNone)
+
+	assert.NoError(t, authService.Logout(ctx, model.LogoutRequest{Token: issued.Token}))
+	// Storing another revocation prunes the entries that have expired.
+	assert.NoError(t, revocations.Revoke(ctx, "other", time.Now().Add(time.Hour)))
+	assertReason(t, authService, issued.Token, model.TokenInvalidReasonRevoked)
+}
+
+func TestAuthService_RevokeToken(t *testing.T) {
+	ctx := context.Background()
+	revocations := driven.NewMemoryRevocationStore()
+	user := newRevokingAuthService(&model.GetUserResponse{Id: "123"}, revocations)
+	issued := login(t, user)
+	claims, err := driven.NewTokenService("test-secret", time.Hour).GetToken(issued.Token)
+	assert.NoError(t, err)
+
+	err = user.RevokeToken(ctx, model.RevokeTokenRequest{Token: issued.Token, Identity: claims.Identity})
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	assertReason(t, user, issued.Token, model.TokenInvalidReasonNone)
+
+	admin := newRevokingAuthService(&model.GetUserResponse{Id: "1", IsAdmin: true}, revocations)
+	adminToken := login(t, admin).Token
+
+	err = admin.RevokeToken(ctx, model.RevokeTokenRequest{Token: adminToken, Identity: claims.Identity})
+	assert.NoError(t, err)
+	assertReason(t, user, issued.Token, model.TokenInvalidReasonRevoked)
+}
+
+func TestAuthService_RevokeAllForUser(t *testing.T) {
+	ctx := context.Background()
+	revocations := driven.NewMemoryRevocationStore()
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123"}, nil)
+	now := time.Now().Add(-time.Second)
+	tokenService := driven.NewTokenService("test-secret", time.Hour, driven.WithRevocationStore(revocations),
+		driven.WithClock(func() time.Time { return now }))
+	authService := NewAuthService(userService, tokenService,
+		WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+		WithRevocationStore(revocations),
+	)
+	first := login(t, authService)
+	second := login(t, authService)
+
+	err := authService.RevokeAllForUser(ctx, model.RevokeAllForUserRequest{Token: first.Token, UserId: "456"})
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	err = authService.RevokeAllForUser(ctx, model.RevokeAllForUserRequest{Token: first.Token, UserId: "123"})
+	assert.NoError(t, err)
+
+	assertReason(t, authService, first.Token, model.TokenInvalidReasonRevoked)
+	assertReason(t, authService, second.Token, model.TokenInvalidReasonRevoked)
+
+	_, err = authService.RefreshToken(ctx, model.RefreshTokenRequest{RefreshToken: second.RefreshToken})
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenInvalid)
+
+	// Logging in again right away works.
+	now = time.Now().Add(time.Millisecond)
+	assertReason(t, authService, login(t, authService).Token, model.TokenInvalidReasonNone)
+}
@@ -0,0 +1,67 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// userSubject builds the token subject of an authenticated user logging in
+// through client, which is nil for first-party logins. Without requested
+// scopes the token gets every permission of the user's role the client is
+// registered for; otherwise exactly the requested scopes, each of which must
+// be a permission of the role or one of userScopes, and registered for the
+// client.
+func (as AuthService) userSubject(ctx context.Context, user *model.GetUserResponse, requested []string, client *model.Client) (model.Subject, error) {
+	subject := model.Subject{UserId: user.Id, IsAdmin: user.IsAdmin, RoleId: user.RoleId}
+
+	permissions, err := as.rolePermissions(ctx, user.RoleId)
+	if err != nil {
+		return subject, err
+	}
+
+	if len(requested) == 0 {
+		var granted []string
+		for _, permission := range permissions {
+			if client == nil || client.HasScope(permission) {
+				granted = append(granted, permission)
+			}
+		}
+		subject.Scopes = granted
+		return subject, nil
+	}
+	if err := checkClientScopes(client, requested); err != nil {
+		return subject, err
+	}
+	for _, scope := range requested {
+		if !model.HasScope(userScopes, scope) && !model.HasScope(permissions, scope) {
+			return subject, domain.ErrScopeInvalid
+		}
+	}
+	subject.Scopes = requested
+	return subject, nil
+}
+
+// checkClientScopes rejects scopes client is not registered for. Without a
+// client there is nothing to check.
+func checkClientScopes(client *model.Client, scopes []string) error {
+	if client == nil {
+		return nil
+	}
+	for _, scope := range scopes {
+		if !client.HasScope(scope) {
+			return domain.ErrScopeInvalid
+		}
+	}
+	return nil
+}
+
+// rolePermissions returns the permissions of roleId, or none when no role
+// policy is configured.
+func (as AuthService) rolePermissions(ctx context.Context, roleId string) ([]string, error) {
+	if as.roles == nil || roleId == "" {
+		return nil, nil
+	}
+	return as.roles.Permissions(ctx, roleId)
+}
@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthService_CreateTokenRoleScopes(t *testing.T) {
+	ctx := context.Background()
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", RoleId: "accountant"}, nil)
+	authService := NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		WithRolePolicy(driven.NewMemoryRolePolicy(map[string][]string{
+			"accountant": {"transactions:read", "transactions:write"},
+		})),
+	)
+	login := func(scopes ...string) (*model.CreateTokenResponse, error) {
+		return authService.CreateToken(ctx, model.CreateTokenRequest{Username: "user", Password: "pass", Scopes: scopes})
+	}
+
+	response, err := login()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"transactions:read", "transactions:write"}, response.Scopes)
+	validated, err := authService.ValidateToken(ctx, model.ValidateTokenRequest{Token: response.Token})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Subject{UserId: "123", RoleId: "accountant", Scopes: []string{"transactions:read", "transactions:write"}}, validated.Subject)
+
+	response, err = login("transactions:read", model.ScopeProfile)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"transactions:read", model.ScopeProfile}, response.Scopes)
+
+	_, err = login("users:write")
+	assert.ErrorIs(t, err, domain.ErrScopeInvalid)
+
+	// Without a policy users only get the OpenID Connect scopes they ask for.
+	response, err = NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour)).
+		CreateToken(ctx, model.CreateTokenRequest{Username: "user", Password: "pass"})
+	assert.NoError(t, err)
+	assert.Empty(t, response.Scopes)
+}
+
+func TestAuthService_ClientClampsRoleScopes(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registry, err := driven.NewMemoryClientRegistry(model.Client{Id: "reports", SecretHash: string(hash), Scopes: []string{"transactions:read"}})
+	assert.NoError(t, err)
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123", RoleId: "accountant"}, nil)
+	authService := NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour),
+		WithClientRegistry(registry),
+		WithRolePolicy(driven.NewMemoryRolePolicy(map[string][]string{
+			"accountant": {"transactions:read", "transactions:write"},
+		})),
+	)
+	login := func(scopes ...string) (*model.CreateTokenResponse, error) {
+		return authService.CreateToken(ctx, model.CreateTokenRequest{
+			Username:     "user",
+			Password:     "pass",
+			Scopes:       scopes,
+			ClientId:     "reports",
+			ClientSecret: "s3cret",
+		})
+	}
+
+	response, err := login()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"transactions:read"}, response.Scopes)
+
+	_, err = login("transactions:write")
+	assert.ErrorIs(t, err, domain.ErrScopeInvalid)
+	_, err = login(model.ScopeProfile)
+	assert.ErrorIs(t, err, domain.ErrScopeInvalid)
+}
@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// ListSigningKeys returns every key of the key ring. Only admins may call it.
+func (as AuthService) ListSigningKeys(ctx context.Context, dto model.ListSigningKeysRequest) ([]model.SigningKeyInfo, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := as.authorizeKeyManagement(ctx, dto.Token); err != nil {
+		return nil, err
+	}
+
+	return as.keyManager.ListKeys(ctx)
+}
+
+// RotateSigningKey activates a new signing key. Tokens signed by the previous
+// key keep verifying until they expire. Only admins may call it.
+func (as AuthService) RotateSigningKey(ctx context.Context, dto model.RotateSigningKeyRequest) (*model.SigningKeyInfo, error) {
+	if err := dto.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := as.authorizeKeyManagement(ctx, dto.Token); err != nil {
+		return nil, err
+	}
+
+	key, err := as.keyManager.Rotate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (as AuthService) authorizeKeyManagement(ctx context.Context, token string) error {
+	if as.keyManager == nil {
+		return domain.ErrUnsupported
+	}
+
+	_, caller, err := as.authenticate(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !caller.IsAdmin {
+		return domain.ErrPermissionDenied
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/domain"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthService_RotateSigningKey(t *testing.T) {
+	ctx := context.Background()
+	key, err := driven.GenerateSigningKey(driven.AlgorithmES256)
+	assert.NoError(t, err)
+	keyRing, err := driven.NewKeyRing(key, driven.WithRotationGrace(time.Hour),
+		driven.WithKeyRingFile(filepath.Join(t.TempDir(), "keyring.json")))
+	assert.NoError(t, err)
+	tokenService := driven.NewTokenServiceWithKeyRing(keyRing, time.Hour)
+
+	newService := func(user *model.GetUserResponse) *AuthService {
+		userService := driven.NewMockUserService()
+		userService.SetGetUserResponse(user, nil)
+		return NewAuthService(userService, tokenService,
+			WithRefreshTokens(driven.NewMemoryRefreshTokenStore(), time.Hour),
+			WithKeyManager(keyRing),
+		)
+	}
+	user := newService(&model.GetUserResponse{Id: "123"})
+	admin := newService(&model.GetUserResponse{Id: "1", IsAdmin: true})
+	userToken := login(t, user).Token
+	adminToken := login(t, admin).Token
+
+	_, err = user.RotateSigningKey(ctx, model.RotateSigningKeyRequest{Token: userToken})
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	_, err = user.ListSigningKeys(ctx, model.ListSigningKeysRequest{Token: userToken})
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	rotated, err := admin.RotateSigningKey(ctx, model.RotateSigningKeyRequest{Token: adminToken})
+	assert.NoError(t, err)
+	assert.Equal(t, model.SigningKeyStateActive, rotated.State)
+
+	keys, err := admin.ListSigningKeys(ctx, model.ListSigningKeysRequest{Token: adminToken})
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, model.SigningKeyStateVerifyOnly, keys[0].State)
+
+	// Tokens issued before the rotation are still valid.
+	assertReason(t, user, userToken, model.TokenInvalidReasonNone)
+
+	unmanaged := NewAuthService(driven.NewMockUserService(), tokenService)
+	_, err = unmanaged.ListSigningKeys(ctx, model.ListSigningKeysRequest{Token: adminToken})
+	assert.ErrorIs(t, err, domain.ErrUnsupported)
+}
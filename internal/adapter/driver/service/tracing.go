@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/nullexp/finman-auth-service/internal/adapter/driver/service"
+
+// WithTracerProvider records the spans around token operations with provider
+// instead of the global tracer provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(as *AuthService) {
+		as.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// signAccessToken signs an access token for subject.
+func (as AuthService) signAccessToken(ctx context.Context, subject model.Subject) (string, error) {
+	_, span := as.tracer.Start(ctx, "TokenService.CreateToken", trace.WithAttributes(attribute.String("auth.token.kind", string(model.TokenKindAccess))))
+	token, err := as.tokenService.CreateToken(subject)
+	endSpan(span, err)
+	if err != nil {
+		return "", err
+	}
+	as.metrics.CountTokenIssued(model.TokenKindAccess)
+	return token, nil
+}
+
+// signIdToken signs an ID token with claims.
+func (as AuthService) signIdToken(ctx context.Context, claims model.IdTokenClaims) (string, error) {
+	_, span := as.tracer.Start(ctx, "TokenService.CreateIdToken", trace.WithAttributes(attribute.String("auth.token.kind", string(model.TokenKindId))))
+	token, err := as.tokenService.CreateIdToken(claims)
+	endSpan(span, err)
+	if err != nil {
+		return "", err
+	}
+	as.metrics.CountTokenIssued(model.TokenKindId)
+	return token, nil
+}
+
+// verifyToken verifies token and returns its claims.
+func (as AuthService) verifyToken(ctx context.Context, token string) (model.StandardClaims, error) {
+	_, span := as.tracer.Start(ctx, "TokenService.GetToken")
+	claims, err := as.tokenService.GetToken(token)
+	if reason, ok := tokenInvalidReason(err); ok {
+		span.SetAttributes(attribute.String("auth.token.invalid_reason", string(reason)))
+	}
+	endSpan(span, err)
+	return claims, err
+}
+
+// endSpan marks span as failed when err is set and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func defaultTracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
@@ -0,0 +1,44 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAuthService_TokenSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	userService := driven.NewMockUserService()
+	userService.SetGetUserResponse(&model.GetUserResponse{Id: "123"}, nil)
+	as := NewAuthService(userService, driven.NewTokenService("test-secret", time.Hour), WithTracerProvider(provider))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "Login")
+	response, err := as.CreateToken(ctx, model.CreateTokenRequest{Username: "validUser", Password: "validPass"})
+	assert.NoError(t, err)
+	_, err = as.ValidateToken(ctx, model.ValidateTokenRequest{Token: response.Token + "x"})
+	assert.NoError(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+	create, validate := spans[0], spans[1]
+	assert.Equal(t, "TokenService.CreateToken", create.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), create.Parent.SpanID())
+	assert.Equal(t, codes.Unset, create.Status.Code)
+
+	assert.Equal(t, "TokenService.GetToken", validate.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), validate.Parent.SpanID())
+	assert.Equal(t, codes.Error, validate.Status.Code)
+	assert.Contains(t, validate.Attributes, attribute.String("auth.token.invalid_reason", string(model.TokenInvalidReasonSignature)))
+}
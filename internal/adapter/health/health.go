@@ -0,0 +1,169 @@
+// Package health decides whether the service is ready to take traffic and
+// publishes the answer through the standard grpc.health.v1 service.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	grpcHealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// checkTimeout bounds a single run of a check.
+const checkTimeout = 2 * time.Second
+
+// Check reports why a dependency cannot be used, or nil when it can.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks of the service. The service is ready
+// once every check passed on the last refresh; until the first refresh it is
+// not. The result is served by the grpc.health.v1 service for the overall
+// server ("") and for each of the services passed to NewChecker.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       []namedCheck
+	results      map[string]error
+	shuttingDown bool
+	services     []string
+	server       *grpcHealth.Server
+}
+
+func NewChecker(services ...string) *Checker {
+	c := &Checker{
+		results:  map[string]error{},
+		services: append([]string{""}, services...),
+		server:   grpcHealth.NewServer(),
+	}
+	c.publish(false)
+	return c
+}
+
+// AddCheck adds a check that has to pass for the service to be ready.
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Server returns the grpc.health.v1 service to register on the gRPC server.
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// Refresh runs every check and publishes the result.
+func (c *Checker) Refresh(ctx context.Context) {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make(map[string]error, len(checks))
+	for _, nc := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		results[nc.name] = nc.check(checkCtx)
+		cancel()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = results
+	if !c.shuttingDown {
+		c.publish(c.ready())
+	}
+}
+
+// Run refreshes the checks right away and then every interval until ctx is
+// done.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ready reports whether the service is ready and the outcome of each check,
+// "ok" or the reason it failed.
+func (c *Checker) Ready() (bool, map[string]string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report := make(map[string]string, len(c.checks))
+	for _, nc := range c.checks {
+		err, ran := c.results[nc.name]
+		switch {
+		case !ran:
+			report[nc.name] = "not checked yet"
+		case err != nil:
+			report[nc.name] = err.Error()
+		default:
+			report[nc.name] = "ok"
+		}
+	}
+	if c.shuttingDown {
+		report["shutdown"] = "shutting down"
+	}
+	return c.ready() && !c.shuttingDown, report
+}
+
+// Shutdown reports the service as not serving from now on, so traffic moves
+// elsewhere while the calls in flight drain.
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shuttingDown = true
+	c.server.Shutdown()
+}
+
+// ready reports whether every check ran and passed. Callers must hold the lock.
+func (c *Checker) ready() bool {
+	for _, nc := range c.checks {
+		if err, ran := c.results[nc.name]; !ran || err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Checker) publish(ready bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if ready {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+}
+
+// ConnectionCheck passes while conn is connected. An idle connection is
+// asked to connect, and the check waits for it as long as ctx allows.
+func ConnectionCheck(conn *grpc.ClientConn) Check {
+	return func(ctx context.Context) error {
+		state := conn.GetState()
+		if state == connectivity.Idle {
+			conn.Connect()
+		}
+		for state != connectivity.Ready {
+			if !conn.WaitForStateChange(ctx, state) {
+				return fmt.Errorf("connection to %s is %s", conn.Target(), state)
+			}
+			state = conn.GetState()
+		}
+		return nil
+	}
+}
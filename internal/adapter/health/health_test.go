@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const service = "auth.v1.AuthService"
+
+func servingStatus(t *testing.T, c *Checker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := c.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	assert.NoError(t, err)
+	return resp.GetStatus()
+}
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	c := NewChecker(service)
+	keysErr := errors.New("active key has no private key")
+	c.AddCheck("user_service", func(ctx context.Context) error { return nil })
+	c.AddCheck("signing_keys", func(ctx context.Context) error { return keysErr })
+
+	// Nothing was checked yet.
+	ready, report := c.Ready()
+	assert.False(t, ready)
+	assert.Equal(t, "not checked yet", report["user_service"])
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, c, ""))
+
+	c.Refresh(ctx)
+	ready, report = c.Ready()
+	assert.False(t, ready)
+	assert.Equal(t, map[string]string{"user_service": "ok", "signing_keys": keysErr.Error()}, report)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, c, service))
+
+	keysErr = nil
+	c.Refresh(ctx)
+	ready, _ = c.Ready()
+	assert.True(t, ready)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, c, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, c, service))
+
+	// Once shutting down the service stays unready.
+	c.Shutdown()
+	c.Refresh(ctx)
+	ready, report = c.Ready()
+	assert.False(t, ready)
+	assert.Equal(t, "shutting down", report["shutdown"])
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, c, ""))
+}
+
+func TestConnectionCheck(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// The idle connection is connected by the check.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, ConnectionCheck(conn)(ctx))
+
+	server.Stop()
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, ConnectionCheck(conn)(ctx), "bufnet")
+}
@@ -0,0 +1,225 @@
+// Package lifecycle starts the servers and background workers of the service
+// together and stops them in order when it is asked to shut down.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// component is something that runs until it is stopped.
+type component struct {
+	name string
+	// run blocks until the component stops; ctx is cancelled when workers
+	// should return.
+	run func(ctx context.Context) error
+	// stop asks a server to stop and waits at most until ctx is done for the
+	// calls it is handling.
+	stop func(ctx context.Context) error
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Manager runs servers and workers until its context is cancelled or one of
+// them fails. It then stops the servers, letting them finish the calls in
+// flight for up to the drain timeout, stops the workers and closes the
+// dependencies in the reverse order they were added.
+type Manager struct {
+	drainTimeout time.Duration
+	logger       *slog.Logger
+	servers      []component
+	workers      []component
+	closers      []closer
+	hooks        []func()
+	closeOnce    sync.Once
+	closeErr     error
+}
+
+type Option func(*Manager)
+
+// WithLogger sets the logger that reports starting and stopping components.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+func NewManager(drainTimeout time.Duration, opts ...Option) *Manager {
+	m := &Manager{drainTimeout: drainTimeout, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// AddGrpcServer serves s on lis. On shutdown s stops gracefully, and is
+// stopped forcibly when calls are still running after the drain timeout.
+func (m *Manager) AddGrpcServer(name string, s *grpc.Server, lis net.Listener) {
+	m.servers = append(m.servers, component{
+		name: name,
+		run: func(context.Context) error {
+			m.logger.Info("gRPC server listening", "server", name, "address", lis.Addr().String())
+			return s.Serve(lis)
+		},
+		stop: func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				s.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				// Closes the connections; handlers ignoring their context
+				// are not waited for.
+				s.Stop()
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// AddHttpServer serves s on lis, which the caller binds up front so a bad
+// address fails at startup. On shutdown s stops accepting requests, and its
+// connections are closed when requests are still running after the drain
+// timeout.
+func (m *Manager) AddHttpServer(name string, s *http.Server, lis net.Listener) {
+	m.servers = append(m.servers, component{
+		name: name,
+		run: func(context.Context) error {
+			m.logger.Info("HTTP server listening", "server", name, "address", lis.Addr().String())
+			if err := s.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		stop: func(ctx context.Context) error {
+			if err := s.Shutdown(ctx); err != nil {
+				s.Close()
+				return err
+			}
+			return nil
+		},
+	})
+}
+
+// AddWorker runs fn in the background. Its context is cancelled once the
+// servers stopped, and fn should return soon after.
+func (m *Manager) AddWorker(name string, fn func(ctx context.Context)) {
+	m.workers = append(m.workers, component{
+		name: name,
+		run: func(ctx context.Context) error {
+			fn(ctx)
+			return nil
+		},
+	})
+}
+
+// AddCloser registers a dependency to close on shutdown, after the servers
+// and workers stopped. Closers run in the reverse order they were added, so
+// a dependency added before the ones using it outlives them.
+func (m *Manager) AddCloser(name string, fn func() error) {
+	m.closers = append(m.closers, closer{name: name, close: fn})
+}
+
+// OnShutdown registers fn to run as soon as shutdown starts, before the
+// servers stop taking calls.
+func (m *Manager) OnShutdown(fn func()) {
+	m.hooks = append(m.hooks, fn)
+}
+
+// Run starts every server and worker and blocks until ctx is cancelled, for
+// example by SIGTERM, or a server stops on its own. It then shuts everything
+// down and returns the error that ended the run, if any, joined with the
+// errors of the closers. Servers that had to be stopped after the drain
+// timeout are only logged, as that is how a shutdown is meant to end then.
+func (m *Manager) Run(ctx context.Context) error {
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	failed := make(chan error, len(m.servers))
+	var servers sync.WaitGroup
+	for _, c := range m.servers {
+		servers.Add(1)
+		go func(c component) {
+			defer servers.Done()
+			if err := c.run(ctx); err != nil {
+				failed <- fmt.Errorf("%s: %w", c.name, err)
+				return
+			}
+			// A server returning before shutdown has stopped serving all the same.
+			failed <- nil
+		}(c)
+	}
+	var workers sync.WaitGroup
+	for _, c := range m.workers {
+		workers.Add(1)
+		go func(c component) {
+			defer workers.Done()
+			c.run(workerCtx)
+		}(c)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		m.logger.Info("Shutting down", "drain_timeout", m.drainTimeout.String())
+	case runErr = <-failed:
+		m.logger.Error("Server stopped, shutting down", "error", runErr)
+	}
+
+	for _, hook := range m.hooks {
+		hook()
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), m.drainTimeout)
+	defer cancel()
+	var stopping sync.WaitGroup
+	for _, c := range m.servers {
+		stopping.Add(1)
+		go func(c component) {
+			defer stopping.Done()
+			if err := c.stop(drainCtx); err != nil {
+				m.logger.Warn("Server did not drain in time", "server", c.name, "error", err)
+			}
+		}(c)
+	}
+	stopping.Wait()
+	servers.Wait()
+
+	stopWorkers()
+	workers.Wait()
+
+	closeErr := m.Close()
+	m.logger.Info("Shutdown complete")
+	return errors.Join(runErr, closeErr)
+}
+
+// Close runs the closers in the reverse order they were added. Run calls it
+// after the servers and workers stopped; call it directly when the service
+// gives up before Run. Only the first call closes anything.
+func (m *Manager) Close() error {
+	m.closeOnce.Do(func() {
+		var errs []error
+		for i := len(m.closers) - 1; i >= 0; i-- {
+			if err := m.closers[i].close(); err != nil {
+				m.logger.Error("Error closing dependency", "name", m.closers[i].name, "error", err)
+				errs = append(errs, err)
+			}
+		}
+		m.closeErr = errors.Join(errs...)
+	})
+	return m.closeErr
+}
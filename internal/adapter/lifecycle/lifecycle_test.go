@@ -0,0 +1,163 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// slowServer answers every call once release is closed, unless the call is
+// cancelled first.
+func slowServer(started chan<- struct{}, release <-chan struct{}) *grpc.Server {
+	return grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		if err := stream.RecvMsg(&emptypb.Empty{}); err != nil {
+			return err
+		}
+		started <- struct{}{}
+		select {
+		case <-release:
+			return stream.SendMsg(&emptypb.Empty{})
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}))
+}
+
+func dial(t *testing.T, lis net.Listener) *grpc.ClientConn {
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestManager_DrainsCallsAndClosesInReverseOrder(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	started, release := make(chan struct{}, 1), make(chan struct{})
+
+	events := &recorder{}
+	m := NewManager(5*time.Second, WithLogger(discard))
+	m.AddGrpcServer("grpc", slowServer(started, release), lis)
+	m.AddWorker("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		events.add("worker stopped")
+	})
+	m.OnShutdown(func() { events.add("shutdown started") })
+	m.AddCloser("first", func() error { events.add("first closed"); return nil })
+	m.AddCloser("second", func() error { events.add("second closed"); return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+
+	called := make(chan error, 1)
+	go func() {
+		called <- dial(t, lis).Invoke(context.Background(), "/test.Slow/Call", &emptypb.Empty{}, &emptypb.Empty{})
+	}()
+	<-started
+	cancel()
+
+	// The call in flight keeps the server, and everything after it, running.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"shutdown started"}, events.get())
+
+	close(release)
+	assert.NoError(t, <-called)
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"shutdown started", "worker stopped", "second closed", "first closed"}, events.get())
+}
+
+func TestManager_StopsServerAfterDrainTimeout(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+
+	closed := false
+	m := NewManager(50*time.Millisecond, WithLogger(discard))
+	m.AddGrpcServer("grpc", slowServer(started, release), lis)
+	m.AddCloser("store", func() error { closed = true; return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+
+	called := make(chan error, 1)
+	go func() {
+		called <- dial(t, lis).Invoke(context.Background(), "/test.Slow/Call", &emptypb.Empty{}, &emptypb.Empty{})
+	}()
+	<-started
+	cancel()
+
+	// Running out of drain time is how this shutdown ends, not a failure.
+	assert.NoError(t, <-done)
+	assert.Equal(t, codes.Unavailable, status.Code(<-called))
+	assert.True(t, closed)
+}
+
+func TestManager_CloseRunsClosersOnce(t *testing.T) {
+	closed := 0
+	m := NewManager(time.Second, WithLogger(discard))
+	m.AddCloser("store", func() error { closed++; return errors.New("closing failed") })
+
+	assert.ErrorContains(t, m.Close(), "closing failed")
+	assert.ErrorContains(t, m.Run(canceled()), "closing failed")
+	assert.Equal(t, 1, closed)
+}
+
+func canceled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestManager_ShutsDownWhenServerFails(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	httpLis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	httpLis.Close()
+
+	closed := false
+	m := NewManager(time.Second, WithLogger(discard))
+	m.AddGrpcServer("grpc", grpc.NewServer(), lis)
+	m.AddHttpServer("http", &http.Server{}, httpLis)
+	m.AddCloser("store", func() error { closed = true; return nil })
+
+	err = m.Run(context.Background())
+	assert.ErrorContains(t, err, "http")
+	assert.True(t, closed)
+}
@@ -0,0 +1,82 @@
+// Package logging builds the structured logger of the service. Every record
+// passes through a RedactingHandler, so credentials never reach the output.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJson = "json"
+
+	// RequestIdKey is the attribute request ids are logged under.
+	RequestIdKey = "request_id"
+	// TraceIdKey and SpanIdKey link records to the trace of the request.
+	TraceIdKey = "trace_id"
+	SpanIdKey  = "span_id"
+)
+
+// New creates a logger writing records of at least level ("debug", "info",
+// "warn" or "error") to w in format (FormatText or FormatJson).
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	options := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJson:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, want %q or %q", format, FormatText, FormatJson)
+	}
+	return slog.New(NewRedactingHandler(handler)), nil
+}
+
+// requestIdPattern limits the request ids callers may choose so they cannot
+// forge log lines.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type requestIdKey struct{}
+
+// WithRequestId returns a copy of ctx carrying id. Records logged with the
+// context include it as request_id.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns the request id stored in ctx, if any.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// NewRequestId returns a random id for a request that came without one.
+func NewRequestId() string {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(raw)
+}
+
+// AcceptRequestId returns the request id a caller sent, or a new one when it
+// sent none or one that is not safe to log.
+func AcceptRequestId(id string) string {
+	if requestIdPattern.MatchString(id) {
+		return id
+	}
+	return NewRequestId()
+}
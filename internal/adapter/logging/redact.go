@@ -0,0 +1,163 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces every value that may hold a credential.
+const Redacted = "[REDACTED]"
+
+var (
+	// sensitiveKeyParts mark attribute and field names holding credentials,
+	// compared after lower-casing and dropping "_" and "-".
+	sensitiveKeyParts = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "credential", "privatekey", "recoverycode", "challenge"}
+	// sensitiveKeys are too short to match as part of other names.
+	sensitiveKeys = map[string]bool{"otp": true, "code": true, "pin": true}
+
+	// scrubPatterns find credentials embedded in free text such as error
+	// messages, query strings and form bodies.
+	scrubPatterns = []struct {
+		pattern     *regexp.Regexp
+		replacement string
+	}{
+		{regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`), Redacted},
+		{regexp.MustCompile(`(?i)(bearer|basic)\s+[^\s"',]+`), "$1 " + Redacted},
+		{regexp.MustCompile(`(?i)\b(password|client_secret|secret|access_token|refresh_token|id_token|mfa_token|token|code|otp|code_verifier)=[^&\s"',]+`), "$1=" + Redacted},
+	}
+)
+
+// RedactingHandler removes credentials from records before passing them on.
+// Attributes whose key names a credential are replaced by Redacted, structs
+// and maps are redacted field by field, and JWTs, bearer tokens and
+// "password=" style pairs are cut out of messages and string values.
+// Records logged with a context also get its request id and trace ids.
+type RedactingHandler struct {
+	next slog.Handler
+}
+
+func NewRedactingHandler(next slog.Handler) *RedactingHandler {
+	return &RedactingHandler{next: next}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, Scrub(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	if id := RequestId(ctx); id != "" {
+		out.AddAttrs(slog.String(RequestIdKey, id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		out.AddAttrs(slog.String(TraceIdKey, span.TraceID().String()), slog.String(SpanIdKey, span.SpanID().String()))
+	}
+	return h.next.Handle(ctx, out)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, redactAttr(a))
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name)}
+}
+
+// Scrub cuts credentials out of free text.
+func Scrub(text string) string {
+	for _, p := range scrubPatterns {
+		text = p.pattern.ReplaceAllString(text, p.replacement)
+	}
+	return text
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, 0, len(group))
+		for _, ga := range group {
+			redacted = append(redacted, redactAttr(ga))
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		return slog.Attr{Key: a.Key, Value: redactAny(a.Value.Any())}
+	default:
+		return a
+	}
+}
+
+// redactAny logs errors by their scrubbed message and everything else by its
+// JSON form with the credentials removed.
+func redactAny(v interface{}) slog.Value {
+	if err, ok := v.(error); ok {
+		return slog.StringValue(Scrub(err.Error()))
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return slog.StringValue(Scrub(fmt.Sprint(v)))
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return slog.StringValue(Redacted)
+	}
+	return slog.AnyValue(redactJson(decoded))
+}
+
+func redactJson(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if isSensitiveKey(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = redactJson(value)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactJson(value)
+		}
+		return v
+	case string:
+		return Scrub(v)
+	default:
+		return v
+	}
+}
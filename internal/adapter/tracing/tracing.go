@@ -0,0 +1,69 @@
+// Package tracing sets up OpenTelemetry tracing for the gRPC server and the
+// connection to the user service. Trace context travels in W3C traceparent
+// and tracestate metadata.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// ServiceName names the service in traces unless OTEL_SERVICE_NAME is set.
+const ServiceName = "finman-auth-service"
+
+// Propagator reads and writes W3C trace context and baggage.
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// NewOtlpTracerProvider creates a tracer provider that exports spans over
+// OTLP/gRPC. The exporter and the sampler are configured by the standard
+// OTEL_EXPORTER_OTLP_* and OTEL_TRACES_SAMPLER* environment variables.
+func NewOtlpTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewTracerProvider(ctx, sdktrace.WithBatcher(exporter))
+}
+
+// NewTracerProvider creates a tracer provider describing this service with
+// the given options, such as sdktrace.WithSyncer and an in-memory exporter
+// in tests.
+func NewTracerProvider(ctx context.Context, opts ...sdktrace.TracerProviderOption) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...), nil
+}
+
+// ServerOption traces every call the server handles, continuing the trace of
+// the caller.
+func ServerOption(provider trace.TracerProvider) grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler(
+		otelgrpc.WithTracerProvider(provider),
+		otelgrpc.WithPropagators(Propagator()),
+	))
+}
+
+// DialOption traces every call made on a client connection and passes the
+// trace context on to the server.
+func DialOption(provider trace.TracerProvider) grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+		otelgrpc.WithTracerProvider(provider),
+		otelgrpc.WithPropagators(Propagator()),
+	))
+}
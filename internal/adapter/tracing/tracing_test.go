@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	userv1 "github.com/nullexp/finman-auth-service/internal/adapter/driver/grpc/proto/user/v1"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeUserServer struct {
+	userv1.UnimplementedUserServiceServer
+	traceparent []string
+}
+
+func (s *fakeUserServer) GetUserById(ctx context.Context, req *userv1.GetUserByIdRequest) (*userv1.GetUserByIdResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.traceparent = md.Get("traceparent")
+	return &userv1.GetUserByIdResponse{User: &userv1.User{Id: req.Id}}, nil
+}
+
+func TestTracePropagatesToUserService(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := NewTracerProvider(context.Background(), sdktrace.WithSyncer(exporter))
+	assert.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	users := &fakeUserServer{}
+	server := grpc.NewServer(ServerOption(provider))
+	userv1.RegisterUserServiceServer(server, users)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		DialOption(provider),
+	)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	ctx, login := provider.Tracer("test").Start(context.Background(), "Login")
+	_, err = userv1.NewUserServiceClient(conn).GetUserById(ctx, &userv1.GetUserByIdRequest{Id: "u1"})
+	assert.NoError(t, err)
+	login.End()
+
+	if assert.Len(t, users.traceparent, 1) {
+		assert.Contains(t, users.traceparent[0], login.SpanContext().TraceID().String())
+	}
+
+	// The server may end its span after the client received the response.
+	assert.Eventually(t, func() bool { return len(exporter.GetSpans()) == 3 }, time.Second, 10*time.Millisecond)
+	spans := map[trace.SpanKind]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.SpanKind] = span
+	}
+	clientSpan, serverSpan := spans[trace.SpanKindClient], spans[trace.SpanKindServer]
+	assert.Equal(t, "user.v1.UserService/GetUserById", clientSpan.Name)
+	assert.Equal(t, login.SpanContext().SpanID(), clientSpan.Parent.SpanID())
+	assert.Equal(t, clientSpan.SpanContext.SpanID(), serverSpan.Parent.SpanID())
+	assert.Equal(t, login.SpanContext().TraceID(), serverSpan.SpanContext.TraceID())
+}
@@ -1,5 +1,86 @@
 package domain
 
-import "errors"
+import "time"
 
-var ErrInvalidAuth = errors.New("INVALID_AUTH: Invalid authentication info")
+// Kind classifies domain errors by how a caller should react to them.
+// Transport adapters map kinds to their own status codes.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalidArgument
+	KindUnauthenticated
+	KindPermissionDenied
+	KindUnimplemented
+	KindUnavailable
+	KindResourceExhausted
+)
+
+// Error is an entry of the error catalog. Code is stable and machine-readable
+// so clients can branch on it; Message is meant for humans and may change.
+type Error struct {
+	Code    string
+	Kind    Kind
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newError(kind Kind, code, message string) *Error {
+	return &Error{Code: code, Kind: kind, Message: message}
+}
+
+var (
+	ErrInvalidArgument        = newError(KindInvalidArgument, "INVALID_ARGUMENT", "Request is invalid")
+	ErrInvalidAuth            = newError(KindUnauthenticated, "INVALID_AUTH", "Invalid authentication info")
+	ErrTokenMalformed         = newError(KindUnauthenticated, "TOKEN_MALFORMED", "Token is malformed")
+	ErrTokenSignatureInvalid  = newError(KindUnauthenticated, "TOKEN_SIGNATURE_INVALID", "Token signature is invalid")
+	ErrTokenExpired           = newError(KindUnauthenticated, "TOKEN_EXPIRED", "Token has expired")
+	ErrTokenNotYetValid       = newError(KindUnauthenticated, "TOKEN_NOT_YET_VALID", "Token is not valid yet")
+	ErrTokenIssuerInvalid     = newError(KindUnauthenticated, "TOKEN_ISSUER_INVALID", "Token was issued by an unexpected issuer")
+	ErrTokenAudienceInvalid   = newError(KindUnauthenticated, "TOKEN_AUDIENCE_INVALID", "Token is not intended for this audience")
+	ErrTokenInvalid           = newError(KindUnauthenticated, "TOKEN_INVALID", "Token is invalid")
+	ErrTokenRevoked           = newError(KindUnauthenticated, "TOKEN_REVOKED", "Token has been revoked")
+	ErrTokenMissing           = newError(KindUnauthenticated, "TOKEN_MISSING", "Bearer token is missing")
+	ErrPermissionDenied       = newError(KindPermissionDenied, "PERMISSION_DENIED", "Caller is not allowed to perform this action")
+	ErrUnsupported            = newError(KindUnimplemented, "UNSUPPORTED", "Operation is not enabled on this server")
+	ErrKeyRotationUnsupported = newError(KindUnimplemented, "KEY_ROTATION_UNSUPPORTED", "Signing key rotation needs an asymmetric key and a persisted key ring")
+	ErrRefreshTokenInvalid    = newError(KindUnauthenticated, "REFRESH_TOKEN_INVALID", "Refresh token is invalid")
+	ErrRefreshTokenExpired    = newError(KindUnauthenticated, "REFRESH_TOKEN_EXPIRED", "Refresh token has expired")
+	ErrRefreshTokenReused     = newError(KindUnauthenticated, "REFRESH_TOKEN_REUSED", "Refresh token was already used")
+	ErrInvalidClient          = newError(KindUnauthenticated, "INVALID_CLIENT", "Invalid client credentials")
+	ErrScopeInvalid           = newError(KindInvalidArgument, "SCOPE_INVALID", "Requested scope is not allowed")
+	ErrRedirectUriInvalid     = newError(KindInvalidArgument, "REDIRECT_URI_INVALID", "Redirect URI is not registered for the client")
+	ErrResponseTypeInvalid    = newError(KindInvalidArgument, "RESPONSE_TYPE_INVALID", "Response type is not supported")
+	ErrCodeChallengeInvalid   = newError(KindInvalidArgument, "CODE_CHALLENGE_INVALID", "A S256 PKCE code challenge is required")
+	ErrAuthCodeInvalid        = newError(KindUnauthenticated, "AUTH_CODE_INVALID", "Authorization code is invalid, expired or already used")
+	ErrUserServiceUnavailable = newError(KindUnavailable, "USER_SERVICE_UNAVAILABLE", "User service is unavailable")
+	ErrRateLimited            = newError(KindResourceExhausted, "RATE_LIMITED", "Too many requests")
+	ErrLoginThrottled         = newError(KindResourceExhausted, "LOGIN_THROTTLED", "Too many failed login attempts, retry later")
+	ErrAccountLocked          = newError(KindResourceExhausted, "ACCOUNT_LOCKED", "Account is temporarily locked")
+	ErrMfaCodeInvalid         = newError(KindUnauthenticated, "MFA_CODE_INVALID", "One-time code is invalid")
+	ErrMfaChallengeInvalid    = newError(KindUnauthenticated, "MFA_CHALLENGE_INVALID", "MFA challenge is invalid, expired or already used")
+	ErrMfaAlreadyEnrolled     = newError(KindInvalidArgument, "MFA_ALREADY_ENROLLED", "Multi-factor authentication is already enabled")
+	ErrMfaNotEnrolled         = newError(KindInvalidArgument, "MFA_NOT_ENROLLED", "Multi-factor authentication has not been enrolled")
+)
+
+// RetryError marks an error as temporary and tells the caller when to retry.
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+// WithRetryAfter wraps err so adapters can report when the call may be retried.
+func WithRetryAfter(err error, retryAfter time.Duration) error {
+	return &RetryError{Err: err, RetryAfter: retryAfter}
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
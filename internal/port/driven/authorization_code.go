@@ -0,0 +1,14 @@
+package driven
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+type AuthorizationCodeStore interface {
+	Save(ctx context.Context, code model.AuthorizationCode) error
+	// Take removes and returns the code with the given hash, so every code can
+	// be redeemed at most once. It returns nil when no such code exists.
+	Take(ctx context.Context, hash string) (*model.AuthorizationCode, error)
+}
@@ -0,0 +1,16 @@
+package driven
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+type ClientRegistry interface {
+	// GetClient returns nil when no client has clientId.
+	GetClient(ctx context.Context, clientId string) (*model.Client, error)
+	// Authenticate returns the client identified by clientId when secret
+	// matches, or nil when the client is unknown or the secret is wrong.
+	// Public clients never authenticate.
+	Authenticate(ctx context.Context, clientId, secret string) (*model.Client, error)
+}
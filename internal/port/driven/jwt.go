@@ -1,6 +1,8 @@
 package driven
 
 import (
+	"time"
+
 	"github.com/nullexp/finman-auth-service/internal/port/model"
 )
 
@@ -19,7 +21,13 @@ type JwtClaim interface {
 }
 
 type TokenService interface {
+	ExpireAfter() time.Duration
+	Leeway() time.Duration
+	Issuer() string
+	Algorithm() string
+	Jwks() model.JsonWebKeySet
 	CreateToken(sb model.Subject) (string, error)
+	CreateIdToken(claims model.IdTokenClaims) (string, error)
 	GetToken(tokenString string) (model.StandardClaims, error)
 	CheckToken(tokenString string) (bool, error)
 	GetSubject(subject string) (out model.Subject, err error)
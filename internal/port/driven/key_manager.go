@@ -0,0 +1,15 @@
+package driven
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+type KeyManager interface {
+	ListKeys(ctx context.Context) ([]model.SigningKeyInfo, error)
+	// Rotate makes a new key active; the previous one stays verify-only until
+	// the tokens it signed have expired. Keys that cannot be rotated safely
+	// fail with domain.ErrKeyRotationUnsupported.
+	Rotate(ctx context.Context) (model.SigningKeyInfo, error)
+}
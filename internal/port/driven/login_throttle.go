@@ -0,0 +1,31 @@
+package driven
+
+import (
+	"context"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// LockoutStore keeps the failed login state per username.
+type LockoutStore interface {
+	Get(ctx context.Context, username string) (model.LoginFailures, error)
+	// RecordFailure counts a failed login at at and returns the new state.
+	RecordFailure(ctx context.Context, username string, at time.Time) (model.LoginFailures, error)
+	Lock(ctx context.Context, username string, until time.Time) error
+	Reset(ctx context.Context, username string) error
+}
+
+// LoginThrottler decides whether a login attempt may reach the user service.
+type LoginThrottler interface {
+	// Allow returns an error when the attempt must be rejected without
+	// checking the credentials. An allowed attempt has to be ended with
+	// Failed, Succeeded or Released.
+	Allow(ctx context.Context, attempt model.LoginAttempt) error
+	Failed(ctx context.Context, attempt model.LoginAttempt) error
+	Succeeded(ctx context.Context, attempt model.LoginAttempt) error
+	// Released ends an attempt whose outcome is unknown, for example because
+	// the user service was unavailable.
+	Released(ctx context.Context, attempt model.LoginAttempt) error
+	Unlock(ctx context.Context, username string) error
+}
@@ -0,0 +1,24 @@
+package driven
+
+import (
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// Metrics records what the service does for monitoring. code is the gRPC
+// status code name of a call, such as "OK" or "Unavailable".
+type Metrics interface {
+	ObserveServerCall(method, code string, duration time.Duration)
+	ObserveUserServiceCall(method, code string, duration time.Duration)
+	CountLogin(outcome model.LoginOutcome)
+	CountTokenIssued(kind model.TokenKind)
+	// CountTokenValidation counts a checked token; reason is
+	// TokenInvalidReasonNone for valid tokens.
+	CountTokenValidation(reason model.TokenInvalidReason)
+	// CountUserServiceRetry counts a retried user service call.
+	CountUserServiceRetry(method string)
+	// SetUserServiceCircuitState records the state the circuit breaker in
+	// front of the user service entered.
+	SetUserServiceCircuitState(state model.CircuitState)
+}
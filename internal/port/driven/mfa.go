@@ -0,0 +1,36 @@
+package driven
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+// Totp implements RFC 6238 time-based one-time passwords.
+type Totp interface {
+	GenerateSecret() (string, error)
+	// Uri returns the otpauth:// URI authenticator apps enroll secret from.
+	Uri(secret, accountName string) string
+	// Verify returns the time step code was generated for, or false when it
+	// does not match any step within the allowed clock skew.
+	Verify(secret, code string) (int64, bool)
+}
+
+type MfaStore interface {
+	// Get returns the enrollment of userId, or nil when there is none.
+	Get(ctx context.Context, userId string) (*model.MfaEnrollment, error)
+	Save(ctx context.Context, enrollment model.MfaEnrollment) error
+	// UseStep records step as used unless a later or equal step already
+	// was, and reports whether it did.
+	UseStep(ctx context.Context, userId string, step int64) (bool, error)
+	// UseRecoveryCode removes the recovery code with the given hash and
+	// reports whether it was still unused.
+	UseRecoveryCode(ctx context.Context, userId, hash string) (bool, error)
+}
+
+type MfaChallengeStore interface {
+	Save(ctx context.Context, challenge model.MfaChallenge) error
+	// Take removes and returns the challenge with the given hash. It returns
+	// nil when no such challenge exists.
+	Take(ctx context.Context, hash string) (*model.MfaChallenge, error)
+}
@@ -0,0 +1,11 @@
+package driven
+
+import (
+	"context"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+type PolicyEngine interface {
+	Evaluate(ctx context.Context, request model.AuthorizationRequest) (model.Decision, error)
+}
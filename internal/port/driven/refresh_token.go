@@ -0,0 +1,18 @@
+package driven
+
+import (
+	"context"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+)
+
+type RefreshTokenStore interface {
+	Save(ctx context.Context, token model.RefreshToken) error
+	// Get returns nil when no token with the given hash exists.
+	Get(ctx context.Context, hash string) (*model.RefreshToken, error)
+	// MarkRotated flags the token as used and reports false if it already was.
+	MarkRotated(ctx context.Context, hash string, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyId string) error
+	RevokeUser(ctx context.Context, userId string) error
+}
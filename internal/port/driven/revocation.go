@@ -0,0 +1,18 @@
+package driven
+
+import (
+	"context"
+	"time"
+)
+
+type RevocationStore interface {
+	// Revoke blocks the token identified by jti; the entry may be forgotten after expiresAt.
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeAllForUser blocks every token issued to userId up to at, which is
+	// kept to at least the millisecond; the entry may be forgotten after expiresAt.
+	RevokeAllForUser(ctx context.Context, userId string, at, expiresAt time.Time) error
+	// RevokedBefore returns the cut-off set by RevokeAllForUser, or the zero time
+	// once it expired.
+	RevokedBefore(ctx context.Context, userId string) (time.Time, error)
+}
@@ -0,0 +1,9 @@
+package driven
+
+import "context"
+
+type RolePolicy interface {
+	// Permissions returns the permissions granted to roleId. Unknown roles
+	// are granted none.
+	Permissions(ctx context.Context, roleId string) ([]string, error)
+}
@@ -8,4 +8,6 @@ import (
 
 type UserService interface {
 	GetUser(ctx context.Context, username, password string) (*model.GetUserResponse, error)
+	// GetUserById returns nil when no user has id.
+	GetUserById(ctx context.Context, id string) (*model.GetUserResponse, error)
 }
@@ -8,4 +8,24 @@ import (
 
 type AuthService interface {
 	CreateToken(context.Context, model.CreateTokenRequest) (*model.CreateTokenResponse, error)
+	CreateClientToken(context.Context, model.ClientCredentialsRequest) (*model.CreateTokenResponse, error)
+	CheckAuthorizeRequest(context.Context, model.AuthorizeRequest) error
+	Authorize(context.Context, model.AuthorizeLoginRequest) (*model.AuthorizeResponse, error)
+	AuthorizeMfa(context.Context, model.AuthorizeMfaRequest) (*model.AuthorizeResponse, error)
+	ExchangeAuthorizationCode(context.Context, model.AuthorizationCodeRequest) (*model.CreateTokenResponse, error)
+	RefreshToken(context.Context, model.RefreshTokenRequest) (*model.CreateTokenResponse, error)
+	ValidateToken(context.Context, model.ValidateTokenRequest) (*model.ValidateTokenResponse, error)
+	CheckPermission(context.Context, model.CheckPermissionRequest) (*model.Decision, error)
+	GetJwks(context.Context) (*model.JsonWebKeySet, error)
+	GetOpenIdConfiguration(context.Context) (*model.OpenIdConfiguration, error)
+	GetUserInfo(context.Context, model.UserInfoRequest) (*model.UserInfo, error)
+	Logout(context.Context, model.LogoutRequest) error
+	RevokeToken(context.Context, model.RevokeTokenRequest) error
+	RevokeAllForUser(context.Context, model.RevokeAllForUserRequest) error
+	EnrollMfa(context.Context, model.EnrollMfaRequest) (*model.EnrollMfaResponse, error)
+	ConfirmMfaEnrollment(context.Context, model.ConfirmMfaEnrollmentRequest) (*model.ConfirmMfaEnrollmentResponse, error)
+	CompleteMfa(context.Context, model.CompleteMfaRequest) (*model.CreateTokenResponse, error)
+	UnlockAccount(context.Context, model.UnlockAccountRequest) error
+	ListSigningKeys(context.Context, model.ListSigningKeysRequest) ([]model.SigningKeyInfo, error)
+	RotateSigningKey(context.Context, model.RotateSigningKeyRequest) (*model.SigningKeyInfo, error)
 }
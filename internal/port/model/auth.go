@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"time"
 
 	validator "github.com/go-playground/validator/v10"
 )
@@ -9,6 +10,14 @@ import (
 type CreateTokenRequest struct {
 	Username string `json:"username" validate:"required,gte=1"`
 	Password string `json:"password" validate:"required,gte=1"`
+	// Scopes may ask for "openid" to also receive an ID token. Its audience
+	// is ClientId, which then has to authenticate with ClientSecret.
+	Scopes       []string `json:"scopes"`
+	ClientId     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	Nonce        string   `json:"nonce"`
+	// ClientIp is the address the login came from, used for throttling.
+	ClientIp string `json:"clientIp"`
 }
 
 func (dto CreateTokenRequest) Validate(ctx context.Context) error {
@@ -17,5 +26,43 @@ func (dto CreateTokenRequest) Validate(ctx context.Context) error {
 }
 
 type CreateTokenResponse struct {
-	Token string `json:"token"`
+	Token        string        `json:"token"`
+	RefreshToken string        `json:"refreshToken,omitempty"`
+	IdToken      string        `json:"idToken,omitempty"`
+	ExpiresIn    time.Duration `json:"expiresIn"`
+	Scopes       []string      `json:"scopes,omitempty"`
+	// MfaChallenge is set instead of the tokens when the user has enrolled a
+	// second factor; CompleteMfa exchanges it for them.
+	MfaChallenge string `json:"mfaChallenge,omitempty"`
+}
+
+// RefreshTokenRequest must name the client the refresh token was issued to,
+// which authenticates with ClientSecret unless it is public.
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+	ClientId     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
+
+func (dto RefreshTokenRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+type ValidateTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (dto ValidateTokenRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+type ValidateTokenResponse struct {
+	Valid     bool               `json:"valid"`
+	Subject   Subject            `json:"subject"`
+	Identity  string             `json:"jti"`
+	IssuedAt  time.Time          `json:"issuedAt"`
+	ExpiresAt time.Time          `json:"expiresAt"`
+	Reason    TokenInvalidReason `json:"reason,omitempty"`
 }
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+// CodeChallengeMethodS256 is the only PKCE method accepted; plain would let
+// anyone who sees the authorization request redeem the code.
+const CodeChallengeMethodS256 = "S256"
+
+// AuthorizeRequest holds the parameters of an OAuth2 authorization request.
+type AuthorizeRequest struct {
+	ResponseType        string   `json:"responseType"`
+	ClientId            string   `json:"clientId" validate:"required"`
+	RedirectUri         string   `json:"redirectUri" validate:"required"`
+	Scopes              []string `json:"scopes"`
+	State               string   `json:"state"`
+	Nonce               string   `json:"nonce"`
+	CodeChallenge       string   `json:"codeChallenge"`
+	CodeChallengeMethod string   `json:"codeChallengeMethod"`
+}
+
+func (dto AuthorizeRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// AuthorizeLoginRequest is an authorization request together with the
+// credentials the user entered on the login form.
+type AuthorizeLoginRequest struct {
+	Authorization AuthorizeRequest `json:"authorization"`
+	Username      string           `json:"username" validate:"required,gte=1"`
+	Password      string           `json:"password" validate:"required,gte=1"`
+	ClientIp      string           `json:"clientIp"`
+}
+
+func (dto AuthorizeLoginRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// AuthorizeResponse holds the authorization code, or the MfaChallenge to
+// complete with AuthorizeMfa when the user has enrolled a second factor.
+type AuthorizeResponse struct {
+	Code         string `json:"code,omitempty"`
+	MfaChallenge string `json:"mfaChallenge,omitempty"`
+}
+
+// AuthorizeMfaRequest is an authorization request together with the MFA
+// challenge of the login and the code the user entered.
+type AuthorizeMfaRequest struct {
+	Authorization AuthorizeRequest `json:"authorization"`
+	Challenge     string           `json:"challenge" validate:"required"`
+	Code          string           `json:"code" validate:"required"`
+	ClientIp      string           `json:"clientIp"`
+}
+
+func (dto AuthorizeMfaRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// AuthorizationCodeRequest redeems an authorization code at the token endpoint.
+type AuthorizationCodeRequest struct {
+	Code         string `json:"code" validate:"required"`
+	RedirectUri  string `json:"redirectUri" validate:"required"`
+	ClientId     string `json:"clientId" validate:"required"`
+	ClientSecret string `json:"clientSecret"`
+	// CodeVerifier is checked with the code, a malformed one fails like a
+	// wrong one.
+	CodeVerifier string `json:"codeVerifier" validate:"required"`
+}
+
+func (dto AuthorizationCodeRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// AuthorizationCode is the stored form of an issued authorization code. Like
+// refresh tokens only the hash of the code is kept.
+type AuthorizationCode struct {
+	Hash          string    `json:"hash"`
+	ClientId      string    `json:"clientId"`
+	RedirectUri   string    `json:"redirectUri"`
+	Subject       Subject   `json:"subject"`
+	Nonce         string    `json:"nonce"`
+	CodeChallenge string    `json:"codeChallenge"`
+	AuthTime      time.Time `json:"authTime"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+}
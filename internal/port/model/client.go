@@ -0,0 +1,46 @@
+package model
+
+import (
+	"context"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+// Client is a registered OAuth2 client. Confidential clients authenticate
+// with a secret and may use the client_credentials grant; public clients such
+// as SPAs and mobile apps have no secret and rely on PKCE.
+type Client struct {
+	Id           string   `json:"clientId"`
+	SecretHash   string   `json:"secretHash"`
+	Public       bool     `json:"public"`
+	Scopes       []string `json:"scopes"`
+	RedirectUris []string `json:"redirectUris"`
+}
+
+// HasScope reports whether the client may be granted scope.
+func (c Client) HasScope(scope string) bool {
+	return HasScope(c.Scopes, scope)
+}
+
+// HasRedirectUri reports whether uri is registered for the client. URIs are
+// compared as exact strings.
+func (c Client) HasRedirectUri(uri string) bool {
+	for _, u := range c.RedirectUris {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+type ClientCredentialsRequest struct {
+	ClientId     string `json:"clientId" validate:"required"`
+	ClientSecret string `json:"clientSecret" validate:"required"`
+	// Scopes narrows the token to a subset of the client's scopes; empty grants all of them.
+	Scopes []string `json:"scopes"`
+}
+
+func (dto ClientCredentialsRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
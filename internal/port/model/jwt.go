@@ -4,6 +4,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/domain"
 )
 
 type StandardClaims struct {
@@ -14,12 +16,62 @@ type StandardClaims struct {
 	Issuer    string   `json:"iss,omitempty"`
 	NotBefore int64    `json:"nbf,omitempty"`
 	Subject   string   `json:"sub,omitempty"`
+	// IssuedAtMilli is iat in milliseconds. iat only has second precision,
+	// too coarse to tell tokens apart from a revocation in the same second.
+	IssuedAtMilli int64 `json:"iat_ms,omitempty"`
+}
+
+// ClaimsValidation describes what the registered claims of a token are checked against.
+// Empty Issuer and Audience are not enforced, and a nil Now falls back to time.Now.
+type ClaimsValidation struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+	Now      func() time.Time
 }
 
 func (c StandardClaims) Valid() error {
+	return c.Validate(ClaimsValidation{})
+}
+
+// Validate checks exp, nbf, iat, iss and aud, tolerating a clock skew of v.Leeway.
+func (c StandardClaims) Validate(v ClaimsValidation) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	if c.ExpiresAt == 0 {
+		return domain.ErrTokenInvalid
+	}
+	if !now.Add(-v.Leeway).Before(time.Unix(c.ExpiresAt, 0)) {
+		return domain.ErrTokenExpired
+	}
+	if c.NotBefore != 0 && now.Add(v.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return domain.ErrTokenNotYetValid
+	}
+	if c.IssuedAt != 0 && now.Add(v.Leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return domain.ErrTokenNotYetValid
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return domain.ErrTokenIssuerInvalid
+	}
+	if v.Audience != "" && !c.HasAudience(v.Audience) {
+		return domain.ErrTokenAudienceInvalid
+	}
+
 	return nil
 }
 
+func (c StandardClaims) HasAudience(audience string) bool {
+	for _, aud := range c.Audience {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
 func (c StandardClaims) GetExpireTime() int64 {
 	return c.ExpiresAt
 }
@@ -40,6 +92,14 @@ func (c StandardClaims) GetIssuedAt() int64 {
 	return c.IssuedAt
 }
 
+// IssuedAtTime returns when the token was issued, as precisely as it says.
+func (c StandardClaims) IssuedAtTime() time.Time {
+	if c.IssuedAtMilli != 0 {
+		return time.UnixMilli(c.IssuedAtMilli)
+	}
+	return time.Unix(c.IssuedAt, 0)
+}
+
 func (c StandardClaims) GetIdentity() string {
 	return c.Identity
 }
@@ -48,6 +108,21 @@ func (c StandardClaims) IsExpired() bool {
 	return time.Now().Unix() > c.ExpiresAt
 }
 
+// TokenInvalidReason explains why a token failed validation.
+type TokenInvalidReason string
+
+const (
+	TokenInvalidReasonNone      TokenInvalidReason = ""
+	TokenInvalidReasonMalformed TokenInvalidReason = "MALFORMED"
+	TokenInvalidReasonSignature TokenInvalidReason = "SIGNATURE_INVALID"
+	TokenInvalidReasonExpired   TokenInvalidReason = "EXPIRED"
+	TokenInvalidReasonNotYet    TokenInvalidReason = "NOT_YET_VALID"
+	TokenInvalidReasonIssuer    TokenInvalidReason = "ISSUER_INVALID"
+	TokenInvalidReasonAudience  TokenInvalidReason = "AUDIENCE_INVALID"
+	TokenInvalidReasonRevoked   TokenInvalidReason = "REVOKED"
+	TokenInvalidReasonInvalid   TokenInvalidReason = "INVALID"
+)
+
 type SubjectParser interface {
 	MustParseSubject(string) Subject
 }
@@ -55,6 +130,12 @@ type SubjectParser interface {
 type Subject struct {
 	UserId  string `json:"userId"`
 	IsAdmin bool   `json:"isAdmin"`
+	RoleId  string `json:"roleId,omitempty"`
+	// ClientId is set instead of UserId on tokens issued to a client for itself.
+	ClientId string `json:"clientId,omitempty"`
+	// Scopes lists what the token grants: the permissions of the user's role
+	// or client, and the OpenID Connect scopes.
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 type testSubjectParser struct {
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+// LoginAttempt identifies who is trying to log in and from where.
+type LoginAttempt struct {
+	Username string `json:"username"`
+	ClientIp string `json:"clientIp"`
+}
+
+// LoginFailures is the failed login state of a username.
+type LoginFailures struct {
+	Count       int       `json:"count"`
+	LastFailure time.Time `json:"lastFailure"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+type UnlockAccountRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Username string `json:"username" validate:"required"`
+}
+
+func (dto UnlockAccountRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
@@ -0,0 +1,38 @@
+package model
+
+// LoginOutcome is how a credential check ended.
+type LoginOutcome string
+
+const (
+	LoginOutcomeSuccess            LoginOutcome = "success"
+	LoginOutcomeInvalidCredentials LoginOutcome = "invalid_credentials"
+	// LoginOutcomeMfaRequired is a correct password of a user with MFA; the
+	// check of the second factor has its own outcome.
+	LoginOutcomeMfaRequired LoginOutcome = "mfa_required"
+	LoginOutcomeThrottled   LoginOutcome = "throttled"
+	LoginOutcomeUnavailable LoginOutcome = "unavailable"
+)
+
+// TokenKind names the kinds of tokens the service issues.
+type TokenKind string
+
+const (
+	TokenKindAccess  TokenKind = "access"
+	TokenKindRefresh TokenKind = "refresh"
+	TokenKindId      TokenKind = "id"
+)
+
+// CircuitState is the state of the circuit breaker in front of the user service.
+type CircuitState string
+
+const (
+	// CircuitClosed lets calls through.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails calls without trying after repeated failures.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single call probe whether the service recovered.
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitStates lists every CircuitState.
+var CircuitStates = []CircuitState{CircuitClosed, CircuitOpen, CircuitHalfOpen}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+// MfaEnrollment is the second factor of a user. It only protects logins once
+// the user proved to have the secret by confirming a code.
+type MfaEnrollment struct {
+	UserId    string `json:"userId"`
+	Secret    string `json:"secret"`
+	Confirmed bool   `json:"confirmed"`
+	// RecoveryCodeHashes holds the hashes of the unused recovery codes.
+	RecoveryCodeHashes []string `json:"recoveryCodeHashes"`
+	// LastStep is the TOTP time step of the last accepted code, so a code
+	// cannot be used twice.
+	LastStep  int64     `json:"lastStep"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// EnrollMfaRequest needs the password of the caller next to the token, so a
+// stolen token alone cannot add a second factor to the account.
+type EnrollMfaRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+	ClientIp string `json:"clientIp"`
+}
+
+func (dto EnrollMfaRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// EnrollMfaResponse carries the new TOTP secret, both plain and as an
+// otpauth:// URI for authenticator apps.
+type EnrollMfaResponse struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type ConfirmMfaEnrollmentRequest struct {
+	Token string `json:"token" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
+
+func (dto ConfirmMfaEnrollmentRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// ConfirmMfaEnrollmentResponse returns the recovery codes. They are shown
+// once; only their hashes are kept.
+type ConfirmMfaEnrollmentResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// CompleteMfaRequest finishes a login with the challenge it returned and a
+// TOTP or recovery code.
+type CompleteMfaRequest struct {
+	Challenge string `json:"challenge" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+	// ClientIp is the address the code came from, used for throttling.
+	ClientIp string `json:"clientIp"`
+}
+
+func (dto CompleteMfaRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// MfaChallenge is the stored form of a login waiting for its second factor.
+// Like refresh tokens only the hash of the challenge is kept.
+type MfaChallenge struct {
+	Hash    string  `json:"hash"`
+	Subject Subject `json:"subject"`
+	// Username is the name the user logged in with, so wrong codes count as
+	// failed logins of that name.
+	Username string    `json:"username"`
+	ClientId string    `json:"clientId"`
+	Nonce    string    `json:"nonce"`
+	AuthTime time.Time `json:"authTime"`
+	// Attempts counts the wrong codes entered for the challenge.
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+const (
+	// ScopeOpenId asks for an ID token next to the access token.
+	ScopeOpenId = "openid"
+	// ScopeProfile releases the profile claims of the user.
+	ScopeProfile = "profile"
+)
+
+// HasScope reports whether scope is one of scopes.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IdTokenClaims are the user claims of an OpenID Connect ID token; the
+// registered claims are added by the token service.
+type IdTokenClaims struct {
+	Subject           string
+	Audience          string
+	Nonce             string
+	AuthTime          time.Time
+	PreferredUsername string
+	UpdatedAt         time.Time
+}
+
+type UserInfoRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (dto UserInfoRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// UserInfo is the response of the OpenID Connect userinfo endpoint.
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	UpdatedAt         int64  `json:"updated_at,omitempty"`
+}
+
+// OpenIdConfiguration is the OpenID Connect discovery document.
+type OpenIdConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"context"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+// Resource describes what an action is performed on. OwnerId and Attributes
+// are supplied by the caller, which knows the resource, and are matched by
+// the conditions of policy rules.
+type Resource struct {
+	Type       string            `json:"type" validate:"required"`
+	Id         string            `json:"id"`
+	OwnerId    string            `json:"ownerId"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+type CheckPermissionRequest struct {
+	Token    string   `json:"token" validate:"required"`
+	Action   string   `json:"action" validate:"required"`
+	Resource Resource `json:"resource"`
+}
+
+func (dto CheckPermissionRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+// PermissionWildcard in a permission stands for any resource type or action.
+const PermissionWildcard = "*"
+
+// GrantsPermission reports whether scopes allow action on resources of
+// resourceType: the permission "<type>:<action>" must be one of them, or be
+// implied by "<type>:*" or "*".
+func GrantsPermission(scopes []string, resourceType, action string) bool {
+	return HasScope(scopes, resourceType+":"+action) ||
+		HasScope(scopes, resourceType+":"+PermissionWildcard) ||
+		HasScope(scopes, PermissionWildcard)
+}
+
+// AuthorizationRequest asks whether Subject may perform Action on Resource.
+type AuthorizationRequest struct {
+	Subject  Subject
+	Action   string
+	Resource Resource
+}
+
+// Decision is the outcome of a permission check. Rule names the policy rule
+// that decided it and is empty when no rule matched, which denies.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule,omitempty"`
+}
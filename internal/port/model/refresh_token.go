@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// RefreshToken is the stored form of an opaque refresh token. Only the hash of
+// the token is kept; FamilyId links every token produced by rotating the one
+// issued at login. ClientId is the client the family was issued to, empty for
+// logins that named none; only that client may refresh it.
+type RefreshToken struct {
+	Hash      string    `json:"hash"`
+	FamilyId  string    `json:"familyId"`
+	ClientId  string    `json:"clientId,omitempty"`
+	Subject   Subject   `json:"subject"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	RotatedAt time.Time `json:"rotatedAt"`
+	Revoked   bool      `json:"revoked"`
+}
+
+func (rt RefreshToken) IsRotated() bool {
+	return !rt.RotatedAt.IsZero()
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"context"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+type LogoutRequest struct {
+	Token        string `json:"token" validate:"required"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (dto LogoutRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+type RevokeTokenRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Identity string `json:"jti" validate:"required"`
+}
+
+func (dto RevokeTokenRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+type RevokeAllForUserRequest struct {
+	Token  string `json:"token" validate:"required"`
+	UserId string `json:"userId" validate:"required"`
+}
+
+func (dto RevokeAllForUserRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
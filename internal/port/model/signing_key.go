@@ -0,0 +1,46 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+// SigningKeyState is the lifecycle stage of a signing key. Exactly one key is
+// active and signs new tokens; verify-only keys still accept the tokens they
+// signed until they are retired.
+type SigningKeyState string
+
+const (
+	SigningKeyStateActive     SigningKeyState = "ACTIVE"
+	SigningKeyStateVerifyOnly SigningKeyState = "VERIFY_ONLY"
+	SigningKeyStateRetired    SigningKeyState = "RETIRED"
+)
+
+type SigningKeyInfo struct {
+	Id        string          `json:"kid"`
+	Algorithm string          `json:"alg"`
+	State     SigningKeyState `json:"state"`
+	CreatedAt time.Time       `json:"createdAt"`
+	// RetireAt is when a verify-only key stops accepting tokens; zero means never.
+	RetireAt time.Time `json:"retireAt"`
+}
+
+type ListSigningKeysRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (dto ListSigningKeysRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
+
+type RotateSigningKeyRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (dto RotateSigningKeyRequest) Validate(ctx context.Context) error {
+	validate := validator.New()
+	return validate.StructCtx(ctx, dto)
+}
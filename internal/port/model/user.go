@@ -1,6 +1,11 @@
 package model
 
+import "time"
+
 type GetUserResponse struct {
-	Id      string `json:"id"`
-	IsAdmin bool   `json:"usAdmin"`
+	Id        string    `json:"id"`
+	IsAdmin   bool      `json:"usAdmin"`
+	RoleId    string    `json:"roleId"`
+	Username  string    `json:"username"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
@@ -0,0 +1,35 @@
+package grpcauth
+
+import "google.golang.org/grpc/codes"
+
+// Error is a failed check of a call. Reason matches the ErrorInfo reason the
+// auth service reports for the same failure, so callers can branch on it the
+// same way; Message is meant for humans and may change.
+type Error struct {
+	Status  codes.Code
+	Reason  string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Reason + ": " + e.Message
+}
+
+func newError(status codes.Code, reason, message string) *Error {
+	return &Error{Status: status, Reason: reason, Message: message}
+}
+
+var (
+	ErrTokenMissing          = newError(codes.Unauthenticated, "TOKEN_MISSING", "Bearer token is missing")
+	ErrTokenMalformed        = newError(codes.Unauthenticated, "TOKEN_MALFORMED", "Token is malformed")
+	ErrTokenSignatureInvalid = newError(codes.Unauthenticated, "TOKEN_SIGNATURE_INVALID", "Token signature is invalid")
+	ErrTokenExpired          = newError(codes.Unauthenticated, "TOKEN_EXPIRED", "Token has expired")
+	ErrTokenNotYetValid      = newError(codes.Unauthenticated, "TOKEN_NOT_YET_VALID", "Token is not valid yet")
+	ErrTokenIssuerInvalid    = newError(codes.Unauthenticated, "TOKEN_ISSUER_INVALID", "Token was issued by an unexpected issuer")
+	ErrTokenAudienceInvalid  = newError(codes.Unauthenticated, "TOKEN_AUDIENCE_INVALID", "Token is not intended for this audience")
+	ErrTokenInvalid          = newError(codes.Unauthenticated, "TOKEN_INVALID", "Token is invalid")
+	ErrPermissionDenied      = newError(codes.PermissionDenied, "PERMISSION_DENIED", "Caller is not allowed to perform this action")
+	// ErrKeysUnavailable is returned while the JWKS of a verifier cannot be
+	// fetched; the call may succeed when retried.
+	ErrKeysUnavailable = newError(codes.Unavailable, "KEYS_UNAVAILABLE", "Token signing keys are unavailable")
+)
@@ -0,0 +1,201 @@
+// Package grpcauth authenticates the gRPC calls of services that accept
+// tokens issued by the auth service. Its interceptors verify the
+// "authorization: Bearer <token>" metadata of every call and make the
+// subject of the token available to handlers through SubjectFromContext.
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	bearerPrefix = "bearer "
+	// errorDomain matches the ErrorInfo domain of the auth service so callers
+	// handle its errors and the ones of downstream services alike.
+	errorDomain = "auth.finman"
+)
+
+// Subject is the identity a token was issued to.
+type Subject struct {
+	UserId  string `json:"userId"`
+	IsAdmin bool   `json:"isAdmin"`
+	RoleId  string `json:"roleId,omitempty"`
+	// ClientId is set instead of UserId on tokens issued to a client for itself.
+	ClientId string `json:"clientId,omitempty"`
+	// Scopes lists what the token grants: the permissions of the user's role
+	// or client, and the OpenID Connect scopes.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (s Subject) HasScope(scope string) bool {
+	for _, granted := range s.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type subjectKey struct{}
+
+// NewContext returns a copy of ctx that carries subject.
+func NewContext(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext returns the subject stored by the interceptors.
+func SubjectFromContext(ctx context.Context) (Subject, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(Subject)
+	return subject, ok
+}
+
+// RequireAdmin fails with PermissionDenied unless the caller is an admin.
+func RequireAdmin(ctx context.Context) error {
+	subject, ok := SubjectFromContext(ctx)
+	if !ok {
+		return toStatus(ctx, ErrTokenMissing)
+	}
+	if !subject.IsAdmin {
+		return toStatus(ctx, ErrPermissionDenied)
+	}
+	return nil
+}
+
+// RequireScope fails with PermissionDenied unless the caller's token was
+// granted scope.
+func RequireScope(ctx context.Context, scope string) error {
+	subject, ok := SubjectFromContext(ctx)
+	if !ok {
+		return toStatus(ctx, ErrTokenMissing)
+	}
+	if !subject.HasScope(scope) {
+		return toStatus(ctx, ErrPermissionDenied)
+	}
+	return nil
+}
+
+// BearerToken extracts the token from the "authorization: Bearer <token>" metadata.
+func BearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", ErrTokenMissing
+	}
+
+	for _, value := range md.Get("authorization") {
+		if len(value) > len(bearerPrefix) && strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(value[len(bearerPrefix):]), nil
+		}
+	}
+
+	return "", ErrTokenMissing
+}
+
+type options struct {
+	public map[string]bool
+}
+
+type Option func(*options)
+
+// WithPublicMethods lets calls of the given full method names, such as
+// "/grpc.health.v1.Health/Check", through without a token.
+func WithPublicMethods(methods ...string) Option {
+	return func(o *options) {
+		for _, method := range methods {
+			o.public[method] = true
+		}
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{public: map[string]bool{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// UnaryServerInterceptor rejects unary calls without a valid bearer token
+// with Unauthenticated and stores the subject of the token in the context.
+func UnaryServerInterceptor(verifier Verifier, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if o.public[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, verifier)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streams without a valid bearer token with
+// Unauthenticated and stores the subject of the token in the stream context.
+func StreamServerInterceptor(verifier Verifier, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if o.public[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), verifier)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, verifier Verifier) (context.Context, error) {
+	token, err := BearerToken(ctx)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	subject, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return NewContext(ctx, subject), nil
+}
+
+// toStatus reports the errors of this package with their status and the
+// reason in an ErrorInfo. Other errors are reported as Internal without
+// exposing their message.
+func toStatus(ctx context.Context, err error) error {
+	var authErr *Error
+	if !errors.As(err, &authErr) {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		slog.ErrorContext(ctx, "Error verifying token", "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+	if authErr.Status == codes.Unavailable {
+		slog.WarnContext(ctx, "Error verifying token", "error", err)
+	}
+	st, detailErr := status.New(authErr.Status, authErr.Message).WithDetails(&errdetails.ErrorInfo{Reason: authErr.Reason, Domain: errorDomain})
+	if detailErr != nil {
+		return status.Error(authErr.Status, authErr.Message)
+	}
+	return st.Err()
+}
@@ -0,0 +1,321 @@
+package grpcauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nullexp/finman-auth-service/internal/adapter/driven"
+	"github.com/nullexp/finman-auth-service/internal/port/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testSecret = "secret"
+
+func incoming(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func issue(t *testing.T, ts *driven.TokenService, subject model.Subject) string {
+	token, err := ts.CreateToken(subject)
+	assert.NoError(t, err)
+	return token
+}
+
+// callUnary runs the interceptor and returns the subject seen by the handler.
+func callUnary(ctx context.Context, interceptor grpc.UnaryServerInterceptor, method string) (Subject, bool, error) {
+	var (
+		subject Subject
+		ok      bool
+	)
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		subject, ok = SubjectFromContext(ctx)
+		return nil, nil
+	})
+	return subject, ok, err
+}
+
+func assertStatus(t *testing.T, err error, code codes.Code, reason string) {
+	st, _ := status.FromError(err)
+	assert.Equal(t, code, st.Code())
+	if reason == "" {
+		return
+	}
+	if assert.Len(t, st.Details(), 1) {
+		assert.Equal(t, reason, st.Details()[0].(*errdetails.ErrorInfo).Reason)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	ts := driven.NewTokenService(testSecret, time.Minute, driven.WithIssuer("auth"))
+	interceptor := UnaryServerInterceptor(NewHMACVerifier(testSecret, WithIssuer("auth")), WithPublicMethods("/svc/Public"))
+
+	want := model.Subject{UserId: "u1", RoleId: "r1", Scopes: []string{"transactions:read"}}
+	subject, ok, err := callUnary(incoming(issue(t, ts, want)), interceptor, "/svc/Call")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Subject{UserId: "u1", RoleId: "r1", Scopes: []string{"transactions:read"}}, subject)
+
+	_, _, err = callUnary(context.Background(), interceptor, "/svc/Call")
+	assertStatus(t, err, codes.Unauthenticated, "TOKEN_MISSING")
+
+	other := driven.NewTokenService("other", time.Minute, driven.WithIssuer("auth"))
+	_, _, err = callUnary(incoming(issue(t, other, want)), interceptor, "/svc/Call")
+	assertStatus(t, err, codes.Unauthenticated, "TOKEN_SIGNATURE_INVALID")
+
+	wrongIssuer := driven.NewTokenService(testSecret, time.Minute, driven.WithIssuer("elsewhere"))
+	_, _, err = callUnary(incoming(issue(t, wrongIssuer, want)), interceptor, "/svc/Call")
+	assertStatus(t, err, codes.Unauthenticated, "")
+
+	_, _, err = callUnary(incoming("garbage"), interceptor, "/svc/Call")
+	assertStatus(t, err, codes.Unauthenticated, "TOKEN_MALFORMED")
+
+	// Public methods run without a token and without a subject.
+	_, ok, err = callUnary(context.Background(), interceptor, "/svc/Public")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestUnaryServerInterceptor_Expired(t *testing.T) {
+	now := time.Now()
+	ts := driven.NewTokenService(testSecret, time.Minute)
+	token := issue(t, ts, model.Subject{UserId: "u1"})
+
+	later := func() time.Time { return now.Add(2 * time.Minute) }
+	interceptor := UnaryServerInterceptor(NewHMACVerifier(testSecret, WithClock(later)))
+	_, _, err := callUnary(incoming(token), interceptor, "/svc/Call")
+	assertStatus(t, err, codes.Unauthenticated, "TOKEN_EXPIRED")
+
+	interceptor = UnaryServerInterceptor(NewHMACVerifier(testSecret, WithClock(later), WithLeeway(2*time.Minute)))
+	_, _, err = callUnary(incoming(token), interceptor, "/svc/Call")
+	assert.NoError(t, err)
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	ts := driven.NewTokenService(testSecret, time.Minute)
+	interceptor := StreamServerInterceptor(NewHMACVerifier(testSecret))
+
+	var subject Subject
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		subject, _ = SubjectFromContext(ss.Context())
+		return nil
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/svc/Stream"}
+
+	stream := fakeServerStream{ctx: incoming(issue(t, ts, model.Subject{UserId: "u1", IsAdmin: true}))}
+	assert.NoError(t, interceptor(nil, stream, info, handler))
+	assert.Equal(t, Subject{UserId: "u1", IsAdmin: true}, subject)
+
+	err := interceptor(nil, fakeServerStream{ctx: context.Background()}, info, handler)
+	assertStatus(t, err, codes.Unauthenticated, "TOKEN_MISSING")
+}
+
+func TestNewPublicKeyVerifier(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	privateDer, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	assert.NoError(t, err)
+
+	signingKey, err := driven.ParseSigningKey(driven.AlgorithmES256, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}))
+	assert.NoError(t, err)
+	ts := driven.NewTokenServiceWithKey(signingKey.WithId("k1"), time.Minute)
+	token := issue(t, ts, model.Subject{UserId: "u1"})
+
+	publicKey := PublicKey{Algorithm: AlgorithmES256, Pem: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})}
+
+	// The kid set with JWT_KEY_ID has to be configured.
+	verifier, err := NewPublicKeyVerifier([]PublicKey{publicKey})
+	assert.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, ErrTokenSignatureInvalid)
+
+	// Without it both sides default to the thumbprint of the key.
+	subject, err := verifier.Verify(context.Background(), issue(t, driven.NewTokenServiceWithKey(signingKey, time.Minute), model.Subject{UserId: "u2"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "u2", subject.UserId)
+
+	publicKey.Id = "k1"
+	verifier, err = NewPublicKeyVerifier([]PublicKey{publicKey})
+	assert.NoError(t, err)
+	subject, err = verifier.Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", subject.UserId)
+
+	_, err = NewPublicKeyVerifier(nil)
+	assert.Error(t, err)
+	_, err = NewPublicKeyVerifier([]PublicKey{{Algorithm: AlgorithmES256, Pem: []byte("nope")}})
+	assert.Error(t, err)
+}
+
+func TestRequireAdminAndScope(t *testing.T) {
+	assertStatus(t, RequireAdmin(context.Background()), codes.Unauthenticated, "TOKEN_MISSING")
+
+	ctx := NewContext(context.Background(), Subject{UserId: "u1", Scopes: []string{"transactions:read"}})
+	assertStatus(t, RequireAdmin(ctx), codes.PermissionDenied, "PERMISSION_DENIED")
+	assert.NoError(t, RequireScope(ctx, "transactions:read"))
+	assertStatus(t, RequireScope(ctx, "transactions:write"), codes.PermissionDenied, "PERMISSION_DENIED")
+
+	ctx = NewContext(context.Background(), Subject{UserId: "u1", IsAdmin: true})
+	assert.NoError(t, RequireAdmin(ctx))
+}
+
+func TestNewJwksVerifier(t *testing.T) {
+	newTokenService := func(alg string) *driven.TokenService {
+		key, err := driven.GenerateSigningKey(alg)
+		assert.NoError(t, err)
+		return driven.NewTokenServiceWithKey(key, time.Hour)
+	}
+	es, ed, rs := newTokenService(driven.AlgorithmES256), newTokenService(driven.AlgorithmEdDSA), newTokenService(driven.AlgorithmRS256)
+
+	var (
+		mu        sync.Mutex
+		published []*driven.TokenService
+		fetches   int
+	)
+	publish := func(services ...*driven.TokenService) {
+		mu.Lock()
+		defer mu.Unlock()
+		published = services
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		var set model.JsonWebKeySet
+		for _, ts := range published {
+			set.Keys = append(set.Keys, ts.Jwks().Keys...)
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(set))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+	verifier, err := NewJwksVerifier(server.URL, WithClock(clock), WithRefreshInterval(time.Minute))
+	assert.NoError(t, err)
+	verify := func(ts *driven.TokenService) error {
+		_, err := verifier.Verify(context.Background(), issue(t, ts, model.Subject{UserId: "u1"}))
+		return err
+	}
+
+	publish(es)
+	assert.NoError(t, verify(es))
+	assert.NoError(t, verify(es))
+	assert.Equal(t, 1, fetches)
+
+	// A rotated key is picked up, but unknown kids do not hammer the endpoint.
+	publish(es, ed)
+	assert.ErrorIs(t, verify(ed), ErrTokenSignatureInvalid)
+	now = now.Add(jwksMinRefetch)
+	assert.NoError(t, verify(ed))
+	assert.Equal(t, 2, fetches)
+
+	// Keys dropped from the set stop verifying once it is refreshed.
+	publish(rs)
+	now = now.Add(time.Minute)
+	assert.NoError(t, verify(rs))
+	assert.ErrorIs(t, verify(es), ErrTokenSignatureInvalid)
+
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+	verifier, err = NewJwksVerifier(broken.URL)
+	assert.NoError(t, err)
+	err = verify(es)
+	assert.ErrorIs(t, err, ErrKeysUnavailable)
+	assertStatus(t, toStatus(context.Background(), err), codes.Unavailable, "KEYS_UNAVAILABLE")
+
+	_, err = NewJwksVerifier("")
+	assert.Error(t, err)
+}
+
+func TestNewJwksVerifier_SlowEndpoint(t *testing.T) {
+	key, err := driven.GenerateSigningKey(driven.AlgorithmES256)
+	assert.NoError(t, err)
+	ts := driven.NewTokenServiceWithKey(key, time.Hour)
+	token := issue(t, ts, model.Subject{UserId: "u1"})
+
+	var (
+		mu   sync.Mutex
+		hang bool
+	)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		wait := hang
+		mu.Unlock()
+		if wait {
+			<-release
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(ts.Jwks()))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var (
+		clockMu sync.Mutex
+		now     = time.Now()
+	)
+	clock := func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		return now
+	}
+	verifier, err := NewJwksVerifier(server.URL, WithClock(clock), WithRefreshInterval(time.Minute))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.NoError(t, err)
+
+	// A refresh hanging on the endpoint does not hold up cached keys.
+	mu.Lock()
+	hang = true
+	mu.Unlock()
+	clockMu.Lock()
+	now = now.Add(time.Minute)
+	clockMu.Unlock()
+	for i := 0; i < 2; i++ {
+		verified := make(chan error, 1)
+		go func() {
+			_, err := verifier.Verify(context.Background(), token)
+			verified <- err
+		}()
+		select {
+		case err := <-verified:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("verification waited for the JWKS endpoint")
+		}
+	}
+
+	// Tokens with an unknown kid wait for the refresh, until they give up.
+	other, err := driven.GenerateSigningKey(driven.AlgorithmES256)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = verifier.Verify(ctx, issue(t, driven.NewTokenServiceWithKey(other, time.Hour), model.Subject{UserId: "u1"}))
+	assert.ErrorIs(t, err, ErrKeysUnavailable)
+}
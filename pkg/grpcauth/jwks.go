@@ -0,0 +1,140 @@
+package grpcauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksMinRefetch limits how often tokens with unknown kids or a failing
+	// endpoint make the verifier fetch the key set.
+	jwksMinRefetch = 10 * time.Second
+	// jwksMaxSize bounds the response read from the JWKS endpoint.
+	jwksMaxSize = 1 << 20
+)
+
+// jwksKeys caches the key set published by the auth service. Fetching runs
+// without the lock, one fetch at a time, so a slow endpoint only holds up
+// tokens whose key is not cached.
+type jwksKeys struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]verificationKey
+	fetchedAt time.Time
+	triedAt   time.Time
+	// fetching is closed when the running fetch is done; nil while none runs.
+	fetching chan struct{}
+}
+
+func newJwksKeys(url string, config verifierConfig) *jwksKeys {
+	return &jwksKeys{url: url, client: config.httpClient, refreshInterval: config.refreshInterval, now: config.now}
+}
+
+func (j *jwksKeys) key(ctx context.Context, kid string) (verificationKey, error) {
+	j.mu.Lock()
+	key, ok := j.keys[kid]
+	done := j.refresh(ctx, !ok)
+	j.mu.Unlock()
+	// Cached keys are served while a refresh runs.
+	if ok {
+		return key, nil
+	}
+
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return verificationKey{}, ErrKeysUnavailable
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.keys == nil {
+		return verificationKey{}, ErrKeysUnavailable
+	}
+	key, ok = j.keys[kid]
+	if !ok {
+		return verificationKey{}, ErrTokenSignatureInvalid
+	}
+	return key, nil
+}
+
+// refresh starts fetching the key set when it is stale or, with missing, may
+// lack a key rotated in since. It returns the channel of the running fetch,
+// or nil when there is none. Callers must hold the lock.
+func (j *jwksKeys) refresh(ctx context.Context, missing bool) chan struct{} {
+	if j.fetching != nil {
+		return j.fetching
+	}
+
+	now := j.now()
+	stale := j.keys == nil || now.Sub(j.fetchedAt) >= j.refreshInterval
+	if (!stale && !missing) || (!j.triedAt.IsZero() && now.Sub(j.triedAt) < jwksMinRefetch) {
+		return nil
+	}
+	j.triedAt = now
+
+	done := make(chan struct{})
+	j.fetching = done
+	// The fetch outlives callers that give up waiting for it.
+	go func() {
+		defer close(done)
+		keys, err := j.fetch(context.WithoutCancel(ctx))
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.fetching = nil
+		if err != nil {
+			slog.WarnContext(ctx, "Error fetching the JWKS", "url", j.url, "error", err)
+			return
+		}
+		j.keys = keys
+		j.fetchedAt = now
+	}()
+	return done
+}
+
+// fetch returns the published keys. Keys that cannot be used are skipped.
+func (j *jwksKeys) fetch(ctx context.Context) (map[string]verificationKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := j.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(io.LimitReader(response.Body, jwksMaxSize)).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]verificationKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.verificationKey()
+		if err != nil {
+			slog.DebugContext(ctx, "Skipping JWKS key", "error", err)
+			continue
+		}
+		keys[key.id] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key set has no usable keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,217 @@
+package grpcauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Algorithms the auth service may sign tokens with.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// verificationKey checks the signatures of the tokens that name id as kid.
+type verificationKey struct {
+	id     string
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// newHMACKey builds the key of a shared secret. Without an id it gets the kid
+// the auth service derives from the secret.
+func newHMACKey(secret, id string) verificationKey {
+	if id == "" {
+		sum := sha256.Sum256(append([]byte("kid:"), secret...))
+		id = hex.EncodeToString(sum[:8])
+	}
+	return verificationKey{id: id, method: jwt.SigningMethodHS256, key: []byte(secret)}
+}
+
+// parsePublicKey parses a PEM encoded public key. Without an id it gets its
+// RFC 7638 thumbprint, the kid the auth service defaults to.
+func parsePublicKey(key PublicKey) (verificationKey, error) {
+	var parsed verificationKey
+	switch key.Algorithm {
+	case AlgorithmRS256:
+		public, err := jwt.ParseRSAPublicKeyFromPEM(key.Pem)
+		if err != nil {
+			return parsed, err
+		}
+		parsed = verificationKey{method: jwt.SigningMethodRS256, key: public}
+	case AlgorithmES256:
+		public, err := jwt.ParseECPublicKeyFromPEM(key.Pem)
+		if err != nil {
+			return parsed, err
+		}
+		if public.Curve != elliptic.P256() {
+			return parsed, fmt.Errorf("ES256 requires a P-256 key, got %s", public.Curve.Params().Name)
+		}
+		parsed = verificationKey{method: jwt.SigningMethodES256, key: public}
+	case AlgorithmEdDSA:
+		public, err := jwt.ParseEdPublicKeyFromPEM(key.Pem)
+		if err != nil {
+			return parsed, err
+		}
+		if _, ok := public.(ed25519.PublicKey); !ok {
+			return parsed, jwt.ErrNotEdPublicKey
+		}
+		parsed = verificationKey{method: jwt.SigningMethodEdDSA, key: public}
+	default:
+		return parsed, fmt.Errorf("unsupported asymmetric signing algorithm %q", key.Algorithm)
+	}
+
+	parsed.id = key.Id
+	if parsed.id == "" {
+		parsed.id = toJsonWebKey(parsed.key).thumbprint()
+	}
+	return parsed, nil
+}
+
+// jsonWebKey is a public key of the JWKS the auth service publishes.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// toJsonWebKey returns the members of an asymmetric public key.
+func toJsonWebKey(key interface{}) jsonWebKey {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return jsonWebKey{Kty: "RSA", N: encodeSegment(key.N.Bytes()), E: encodeSegment(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return jsonWebKey{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   encodeSegment(key.X.FillBytes(make([]byte, size))),
+			Y:   encodeSegment(key.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		return jsonWebKey{Kty: "OKP", Crv: "Ed25519", X: encodeSegment(key)}
+	default:
+		return jsonWebKey{}
+	}
+}
+
+// thumbprint computes the RFC 7638 thumbprint of the key.
+func (k jsonWebKey) thumbprint() string {
+	// Required members only, in lexicographic order.
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return encodeSegment(sum[:])
+}
+
+// verificationKey decodes the key. Keys the auth service would not sign
+// with are refused.
+func (k jsonWebKey) verificationKey() (verificationKey, error) {
+	if k.Kid == "" {
+		return verificationKey{}, fmt.Errorf("key has no kid")
+	}
+	if k.Use != "" && k.Use != "sig" {
+		return verificationKey{}, fmt.Errorf("key %s is not a signing key", k.Kid)
+	}
+
+	parsed := verificationKey{id: k.Kid}
+	switch {
+	case k.Kty == "RSA" && (k.Alg == "" || k.Alg == AlgorithmRS256):
+		n, err := decodeSegment(k.N)
+		if err != nil {
+			return parsed, err
+		}
+		e, err := decodeSegment(k.E)
+		if err != nil {
+			return parsed, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return parsed, fmt.Errorf("key %s has an invalid exponent", k.Kid)
+		}
+		parsed.method = jwt.SigningMethodRS256
+		parsed.key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	case k.Kty == "EC" && k.Crv == "P-256" && (k.Alg == "" || k.Alg == AlgorithmES256):
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return parsed, err
+		}
+		y, err := decodeSegment(k.Y)
+		if err != nil {
+			return parsed, err
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !public.Curve.IsOnCurve(public.X, public.Y) {
+			return parsed, fmt.Errorf("key %s is not on its curve", k.Kid)
+		}
+		parsed.method = jwt.SigningMethodES256
+		parsed.key = public
+	case k.Kty == "OKP" && k.Crv == "Ed25519" && (k.Alg == "" || k.Alg == AlgorithmEdDSA):
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return parsed, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return parsed, fmt.Errorf("key %s has an invalid size", k.Kid)
+		}
+		parsed.method = jwt.SigningMethodEdDSA
+		parsed.key = ed25519.PublicKey(x)
+	default:
+		return parsed, fmt.Errorf("key %s has unsupported type %s %s", k.Kid, k.Kty, k.Alg)
+	}
+	return parsed, nil
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
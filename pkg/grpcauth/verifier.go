@@ -0,0 +1,293 @@
+package grpcauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Verifier checks a bearer token and returns the subject it was issued to.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (Subject, error)
+}
+
+// TokenVerifier verifies tokens locally with the same checks the auth service
+// applies: signature, exp, nbf, iat and, when configured, iss and aud.
+// Revocation is not checked; services that must reject revoked tokens before
+// they expire should call ValidateToken instead.
+type TokenVerifier struct {
+	keys     keySource
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// keySource finds the key a token names in its kid header.
+type keySource interface {
+	key(ctx context.Context, kid string) (verificationKey, error)
+}
+
+// PublicKey is a PEM encoded public key the auth service signs tokens with.
+// Id is the kid of the key; leave it empty unless the auth service sets
+// JWT_KEY_ID, the default is the same thumbprint the auth service uses.
+type PublicKey struct {
+	Algorithm string
+	Id        string
+	Pem       []byte
+}
+
+type verifierConfig struct {
+	keyId           string
+	issuer          string
+	audience        string
+	leeway          time.Duration
+	now             func() time.Time
+	httpClient      *http.Client
+	refreshInterval time.Duration
+}
+
+type VerifierOption func(*verifierConfig)
+
+// WithIssuer rejects tokens whose iss claim is not issuer.
+func WithIssuer(issuer string) VerifierOption {
+	return func(c *verifierConfig) {
+		c.issuer = issuer
+	}
+}
+
+// WithAudience rejects tokens whose aud claim does not contain audience.
+func WithAudience(audience string) VerifierOption {
+	return func(c *verifierConfig) {
+		c.audience = audience
+	}
+}
+
+// WithLeeway tolerates clock skew when checking exp, nbf and iat.
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(c *verifierConfig) {
+		c.leeway = leeway
+	}
+}
+
+// WithClock replaces the clock tokens are checked against.
+func WithClock(now func() time.Time) VerifierOption {
+	return func(c *verifierConfig) {
+		c.now = now
+	}
+}
+
+// WithKeyId sets the kid of the shared secret of NewHMACVerifier, needed when
+// the auth service sets JWT_KEY_ID.
+func WithKeyId(id string) VerifierOption {
+	return func(c *verifierConfig) {
+		c.keyId = id
+	}
+}
+
+// WithHttpClient sets the client NewJwksVerifier fetches the key set with.
+// It should have a timeout, as no other fetch starts while one hangs.
+func WithHttpClient(client *http.Client) VerifierOption {
+	return func(c *verifierConfig) {
+		c.httpClient = client
+	}
+}
+
+// WithRefreshInterval sets how long NewJwksVerifier uses a fetched key set
+// before fetching it again (default five minutes).
+func WithRefreshInterval(interval time.Duration) VerifierOption {
+	return func(c *verifierConfig) {
+		c.refreshInterval = interval
+	}
+}
+
+// NewHMACVerifier verifies HS256 tokens signed with the shared secret.
+func NewHMACVerifier(secret string, opts ...VerifierOption) *TokenVerifier {
+	config := newVerifierConfig(opts)
+	return newTokenVerifier(staticKeys{newHMACKey(secret, config.keyId)}, config)
+}
+
+// NewPublicKeyVerifier verifies tokens signed with the private part of any of
+// keys. The first key also verifies tokens without a kid. Keys rotated by
+// the auth service are not picked up; use NewJwksVerifier for that.
+func NewPublicKeyVerifier(keys []PublicKey, opts ...VerifierOption) (*TokenVerifier, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one public key is required")
+	}
+	parsed := make(staticKeys, 0, len(keys))
+	for _, key := range keys {
+		verificationKey, err := parsePublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, verificationKey)
+	}
+	return newTokenVerifier(parsed, newVerifierConfig(opts)), nil
+}
+
+// NewJwksVerifier verifies tokens with the keys the auth service publishes at
+// jwksUrl, usually "<issuer>/.well-known/jwks.json". The key set is fetched
+// on first use, again after the refresh interval and whenever a token names
+// a key it does not list, so verifiers keep working across key rotations.
+func NewJwksVerifier(jwksUrl string, opts ...VerifierOption) (*TokenVerifier, error) {
+	if jwksUrl == "" {
+		return nil, fmt.Errorf("a JWKS URL is required")
+	}
+	config := newVerifierConfig(opts)
+	return newTokenVerifier(newJwksKeys(jwksUrl, config), config), nil
+}
+
+func newVerifierConfig(opts []VerifierOption) verifierConfig {
+	config := verifierConfig{
+		now:             time.Now,
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: 5 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+func newTokenVerifier(keys keySource, config verifierConfig) *TokenVerifier {
+	return &TokenVerifier{keys: keys, issuer: config.issuer, audience: config.audience, leeway: config.leeway, now: config.now}
+}
+
+// tokenClaims are the registered claims of an access token. Its subject is
+// the base64 encoded JSON of a Subject.
+type tokenClaims struct {
+	Audience  []string `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+}
+
+// Valid is checked by TokenVerifier.validate so that leeway, clock, issuer
+// and audience apply.
+func (c tokenClaims) Valid() error {
+	return nil
+}
+
+var errUnexpectedMethod = errors.New("unexpected signing method")
+
+func (v *TokenVerifier) Verify(ctx context.Context, token string) (Subject, error) {
+	var (
+		claims tokenClaims
+		keyErr error
+	)
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.keys.key(ctx, kid)
+		if err != nil {
+			keyErr = err
+			return nil, err
+		}
+		// Only accept the algorithm of the key so a public key can never be
+		// abused as an HMAC secret.
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, errUnexpectedMethod
+		}
+		return key.key, nil
+	})
+	if keyErr != nil {
+		return Subject{}, keyErr
+	}
+	if err != nil {
+		return Subject{}, parseError(err)
+	}
+
+	if err := v.validate(claims); err != nil {
+		return Subject{}, err
+	}
+
+	subject, err := decodeSubject(claims.Subject)
+	if err != nil {
+		return Subject{}, ErrTokenMalformed
+	}
+	return subject, nil
+}
+
+// validate checks exp, nbf, iat, iss and aud, tolerating the leeway.
+func (v *TokenVerifier) validate(c tokenClaims) error {
+	now := v.now()
+	if c.ExpiresAt == 0 {
+		return ErrTokenInvalid
+	}
+	if !now.Add(-v.leeway).Before(time.Unix(c.ExpiresAt, 0)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != 0 && now.Add(v.leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if c.IssuedAt != 0 && now.Add(v.leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if v.issuer != "" && c.Issuer != v.issuer {
+		return ErrTokenIssuerInvalid
+	}
+	if v.audience != "" && !contains(c.Audience, v.audience) {
+		return ErrTokenAudienceInvalid
+	}
+	return nil
+}
+
+// parseError translates a jwt parse failure into the matching error.
+func parseError(err error) error {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return ErrTokenInvalid
+	}
+
+	switch {
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return ErrTokenMalformed
+	case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return ErrTokenSignatureInvalid
+	default:
+		return ErrTokenInvalid
+	}
+}
+
+func decodeSubject(encoded string) (Subject, error) {
+	var subject Subject
+	data, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return subject, err
+	}
+	err = json.Unmarshal(data, &subject)
+	return subject, err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// staticKeys are configured up front. The first one verifies tokens without
+// a kid, like the active key of the auth service.
+type staticKeys []verificationKey
+
+func (s staticKeys) key(ctx context.Context, kid string) (verificationKey, error) {
+	if kid == "" {
+		return s[0], nil
+	}
+	for _, key := range s {
+		if key.id == kid {
+			return key, nil
+		}
+	}
+	return verificationKey{}, ErrTokenSignatureInvalid
+}